@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are looked for in the scan root, in this order
+var configFileNames = []string{".file-mapper.yaml", ".file-mapper.yml", ".file-mapper.toml"}
+
+// reservedKeys are flags a config file can't set
+var reservedKeys = map[string]bool{"config": true, "profile": true, "path": true, "help": true, "version": true}
+
+// applyConfigFile loads the config file named by --config, or found in the
+// scan root, and uses its values (with the --profile ones on top) for every
+// flag not given on the command line
+func applyConfigFile(ctx *cli.Context) error {
+	path := ctx.String("config")
+	if path == "" {
+		path = findConfigFile(ctx.String("path"))
+	}
+	if path == "" {
+		if profile := ctx.String("profile"); profile != "" {
+			return fmt.Errorf("--profile %s needs a config file (%s in the scan root, or --config)", profile, configFileNames[0])
+		}
+		return nil
+	}
+
+	values, err := loadConfigFile(path, ctx.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	flags := make(map[string]bool)
+	for _, f := range ctx.App.Flags {
+		for _, name := range f.Names() {
+			flags[name] = true
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !flags[key] || reservedKeys[key] {
+			return fmt.Errorf("%s: unknown option %q", path, key)
+		}
+		if ctx.IsSet(key) {
+			continue // the command line wins
+		}
+		if err := ctx.Set(key, values[key]); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+	}
+	return nil
+}
+
+// findConfigFile returns the config file in root, or "" if there's none
+func findConfigFile(root string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(root, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML or TOML config file and returns its values,
+// keyed by flag name, with the named profile's values applied on top
+func loadConfigFile(path, profile string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	profiles, ok := raw["profiles"].(map[string]interface{})
+	if _, found := raw["profiles"]; found && !ok {
+		return nil, fmt.Errorf("%s: profiles must map profile names to options", path)
+	}
+	delete(raw, "profiles")
+
+	values := make(map[string]string)
+	if err := addConfigValues(values, raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if profile == "" {
+		return values, nil
+	}
+
+	options, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%s: unknown profile %q (have: %s)", path, profile, strings.Join(names, ", "))
+	}
+	if err := addConfigValues(values, options); err != nil {
+		return nil, fmt.Errorf("%s: profile %s: %v", path, profile, err)
+	}
+	return values, nil
+}
+
+// addConfigValues converts options to flag values and adds them to values.
+// Lists become comma-separated strings, as --include and --exclude take.
+func addConfigValues(values map[string]string, options map[string]interface{}) error {
+	for key, v := range options {
+		s, err := configValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		values[key] = s
+	}
+	return nil
+}
+
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
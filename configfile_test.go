@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	tmp := t.TempDir()
+	yamlPath := filepath.Join(tmp, ".file-mapper.yaml")
+	os.WriteFile(yamlPath, []byte(`
+gitignore: true
+exclude: [testdata, "*.lock"]
+max-tokens: 8000
+profiles:
+  review:
+    format: markdown
+    max-tokens: 20000
+`), 0644)
+	tomlPath := filepath.Join(tmp, "team.toml")
+	os.WriteFile(tomlPath, []byte(`
+gitignore = true
+exclude = ["testdata", "*.lock"]
+max-tokens = 8000
+
+[profiles.review]
+format = "markdown"
+max-tokens = 20000
+`), 0644)
+
+	want := map[string]string{"gitignore": "true", "exclude": "testdata,*.lock", "max-tokens": "8000"}
+	wantReview := map[string]string{"gitignore": "true", "exclude": "testdata,*.lock", "max-tokens": "20000", "format": "markdown"}
+	for _, path := range []string{yamlPath, tomlPath} {
+		got, err := loadConfigFile(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("loadConfigFile(%s) = %v; want %v", path, got, want)
+		}
+
+		got, err = loadConfigFile(path, "review")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, wantReview) {
+			t.Errorf("loadConfigFile(%s, review) = %v; want %v", path, got, wantReview)
+		}
+
+		if _, err := loadConfigFile(path, "docs"); err == nil || !strings.Contains(err.Error(), `unknown profile "docs" (have: review)`) {
+			t.Errorf("expected an unknown profile error, got %v", err)
+		}
+	}
+
+	if got := findConfigFile(tmp); got != yamlPath {
+		t.Errorf("findConfigFile = %q; want %q", got, yamlPath)
+	}
+	if got := findConfigFile(t.TempDir()); got != "" {
+		t.Errorf("findConfigFile in an empty dir = %q", got)
+	}
+}
+
+func TestMainCLI_ConfigFile(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "test-file-mapper")
+	if out, err := exec.Command("go", "build", "-o", binPath, ".").CombinedOutput(); err != nil {
+		t.Fatalf("Build failed: %v\n%s", err, string(out))
+	}
+
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "hello.txt"), []byte("hello content"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "skip.log"), []byte("noise"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, ".file-mapper.yaml"), []byte(`
+flat: true
+exclude: ["*.log"]
+profiles:
+  llm:
+    content: true
+    format: xml
+`), 0644)
+
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command(binPath, append([]string{"--path", tmpDir}, args...)...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String() + stderr.String(), err
+	}
+
+	out, err := run()
+	if err != nil {
+		t.Fatalf("Command failed: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "skip.log") || strings.Contains(out, "└──") || !strings.Contains(out, "hello.txt") {
+		t.Errorf("config file not applied:\n%s", out)
+	}
+
+	// The profile adds content; flags on the command line still win
+	out, err = run("--profile", "llm", "--format", "markdown")
+	if err != nil {
+		t.Fatalf("Command failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "hello content") || strings.Contains(out, "<documents>") || !strings.Contains(out, "### ") {
+		t.Errorf("unexpected profile output:\n%s", out)
+	}
+
+	if out, err := run("--profile", "nope"); err == nil || !strings.Contains(out, "unknown profile") {
+		t.Errorf("expected an unknown profile error, got %v:\n%s", err, out)
+	}
+}
@@ -2,7 +2,11 @@ module github.com/sky93/file-mapper
 
 go 1.18
 
-require github.com/urfave/cli/v2 v2.27.5
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/urfave/cli/v2 v2.27.5
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.5 // indirect
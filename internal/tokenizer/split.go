@@ -0,0 +1,122 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// nextPiece returns where the pre-tokenizer piece starting at i ends. It
+// follows the cl100k_base split pattern, written out by hand because Go's
+// regexp can't express its (?!\S) lookahead:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func nextPiece(s string, i int) int {
+	r, n := utf8.DecodeRuneInString(s[i:])
+
+	if r == '\'' {
+		if j := contraction(s, i+n); j > 0 {
+			return j
+		}
+	}
+
+	if unicode.IsLetter(r) {
+		return skipLetters(s, i)
+	}
+	if r != '\r' && r != '\n' && !unicode.IsNumber(r) && i+n < len(s) {
+		if next, _ := utf8.DecodeRuneInString(s[i+n:]); unicode.IsLetter(next) {
+			return skipLetters(s, i+n)
+		}
+	}
+
+	if unicode.IsNumber(r) {
+		j := i
+		for k := 0; k < 3 && j < len(s); k++ {
+			d, dn := utf8.DecodeRuneInString(s[j:])
+			if !unicode.IsNumber(d) {
+				break
+			}
+			j += dn
+		}
+		return j
+	}
+
+	start := i
+	if r == ' ' {
+		start++
+	}
+	j := start
+	for j < len(s) {
+		c, cn := utf8.DecodeRuneInString(s[j:])
+		if unicode.IsSpace(c) || unicode.IsLetter(c) || unicode.IsNumber(c) {
+			break
+		}
+		j += cn
+	}
+	if j > start {
+		for j < len(s) && (s[j] == '\r' || s[j] == '\n') {
+			j++
+		}
+		return j
+	}
+
+	if unicode.IsSpace(r) {
+		end, lastNewline := i, -1
+		for end < len(s) {
+			c, cn := utf8.DecodeRuneInString(s[end:])
+			if !unicode.IsSpace(c) {
+				break
+			}
+			end += cn
+			if c == '\r' || c == '\n' {
+				lastNewline = end
+			}
+		}
+		if lastNewline > 0 {
+			return lastNewline
+		}
+		if end == len(s) {
+			return end
+		}
+		// leave the last space to prefix the word that follows
+		_, last := utf8.DecodeLastRuneInString(s[:end])
+		if end-last > i {
+			return end - last
+		}
+		return end
+	}
+
+	return i + n
+}
+
+// contraction returns the end of an English contraction suffix starting at i
+// (just after the apostrophe), or 0 when there is none
+func contraction(s string, i int) int {
+	for _, suffix := range [...]string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		if len(s)-i >= len(suffix) && equalFoldASCII(s[i:i+len(suffix)], suffix) {
+			return i + len(suffix)
+		}
+	}
+	return 0
+}
+
+func equalFoldASCII(s, lower string) bool {
+	for k := 0; k < len(lower); k++ {
+		if s[k]|0x20 != lower[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// skipLetters returns the end of the run of letters starting at i
+func skipLetters(s string, i int) int {
+	for i < len(s) {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		i += n
+	}
+	return i
+}
@@ -0,0 +1,33 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextPiece(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"I'm they'LL", []string{"I", "'m", " they", "'LL"}},
+		{"12345", []string{"123", "45"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"x  \n\n  y", []string{"x", "  \n\n", " ", " y"}},
+		{"end  ", []string{"end", "  "}},
+		{"f(\"hi\")\n}", []string{"f", "(\"", "hi", "\")\n", "}"}},
+		{"\tfmt", []string{"\tfmt"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for i := 0; i < len(c.text); {
+			j := nextPiece(c.text, i)
+			got = append(got, c.text[i:j])
+			i = j
+		}
+		if strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("pieces of %q = %q; want %q", c.text, got, c.want)
+		}
+	}
+}
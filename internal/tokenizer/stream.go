@@ -0,0 +1,65 @@
+package tokenizer
+
+import "bytes"
+
+// streamChunk is how much text Stream buffers before counting it
+const streamChunk = 64 << 10
+
+// Stream counts the tokens of text written to it in any number of pieces,
+// so large files never have to be held in memory at once. It counts in
+// chunks that end at line boundaries; a piece never spans a newline followed
+// by a non-space character, so the total matches counting the whole text.
+type Stream struct {
+	counter Counter
+	buf     []byte
+	total   int
+}
+
+// NewStream returns a Stream that counts with c
+func NewStream(c Counter) *Stream {
+	return &Stream{counter: c}
+}
+
+// Write buffers p and counts every complete chunk. It never fails.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) >= streamChunk {
+		cut := safeCut(s.buf[streamChunk/2:])
+		if cut < 0 {
+			if len(s.buf) < 4*streamChunk {
+				break
+			}
+			// no line breaks at all; accept a slightly off count
+			cut = len(s.buf) - streamChunk/2
+		} else {
+			cut += streamChunk / 2
+		}
+		s.total += s.counter.Count(string(s.buf[:cut]))
+		s.buf = append(s.buf[:0], s.buf[cut:]...)
+	}
+	return len(p), nil
+}
+
+// Total counts whatever is still buffered and returns the running total
+func (s *Stream) Total() int {
+	if len(s.buf) > 0 {
+		s.total += s.counter.Count(string(s.buf))
+		s.buf = s.buf[:0]
+	}
+	return s.total
+}
+
+// safeCut returns the offset just after the first newline in b that is
+// followed by a printable ASCII character, or -1 if there is none
+func safeCut(b []byte) int {
+	for i := 0; ; {
+		nl := bytes.IndexByte(b[i:], '\n')
+		if nl < 0 || i+nl+1 >= len(b) {
+			return -1
+		}
+		i += nl + 1
+		if c := b[i]; c > ' ' && c < 0x7f {
+			return i
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamMatchesCount(t *testing.T) {
+	c, err := New(CL100K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for sb.Len() < 5*streamChunk {
+		sb.WriteString("func example() {\n    return \"some text\" // comment\n}\n\n")
+	}
+	text := sb.String()
+
+	s := NewStream(c)
+	for rest := text; len(rest) > 0; {
+		n := 1000
+		if n > len(rest) {
+			n = len(rest)
+		}
+		s.Write([]byte(rest[:n]))
+		rest = rest[n:]
+	}
+	if got, want := s.Total(), c.Count(text); got != want {
+		t.Errorf("Stream total = %d; Count = %d", got, want)
+	}
+}
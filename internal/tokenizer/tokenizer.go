@@ -0,0 +1,197 @@
+// Package tokenizer counts LLM tokens offline. It ships the cl100k_base
+// vocabulary (the one used by GPT-4 and GPT-3.5) inside the binary and also
+// offers a cheap chars/4 estimate.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tokenizer names accepted by New
+const (
+	CL100K = "cl100k"
+	Chars  = "chars"
+)
+
+// Counter counts the tokens in a piece of text
+type Counter interface {
+	Count(text string) int
+}
+
+// New returns the counter called name. An empty name means CL100K.
+func New(name string) (Counter, error) {
+	switch name {
+	case "", CL100K:
+		ranks, err := cl100kRanks()
+		if err != nil {
+			return nil, err
+		}
+		return &bpe{ranks: ranks}, nil
+	case Chars:
+		return charsCounter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q", name)
+	}
+}
+
+// charsCounter guesses one token per four characters
+type charsCounter struct{}
+
+func (charsCounter) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+//go:embed cl100k_base.tiktoken.gz
+var cl100kData []byte
+
+var (
+	cl100kOnce sync.Once
+	cl100kMap  map[string]int
+	cl100kErr  error
+)
+
+// cl100kRanks decodes the embedded vocabulary the first time it's needed
+func cl100kRanks() (map[string]int, error) {
+	cl100kOnce.Do(func() {
+		cl100kMap, cl100kErr = loadRanks(cl100kData)
+	})
+	return cl100kMap, cl100kErr
+}
+
+// loadRanks parses a gzipped tiktoken file: one "base64-token rank" per line
+func loadRanks(data []byte) (map[string]int, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading vocabulary: %v", err)
+	}
+	defer zr.Close()
+
+	ranks := make(map[string]int, 100256)
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		sp := bytes.IndexByte(line, ' ')
+		if sp < 0 {
+			return nil, fmt.Errorf("malformed vocabulary line %q", line)
+		}
+		token := make([]byte, base64.StdEncoding.DecodedLen(sp))
+		n, err := base64.StdEncoding.Decode(token, line[:sp])
+		if err != nil {
+			return nil, fmt.Errorf("malformed vocabulary line %q: %v", line, err)
+		}
+		rank, err := strconv.Atoi(string(line[sp+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("malformed vocabulary line %q: %v", line, err)
+		}
+		ranks[string(token[:n])] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading vocabulary: %v", err)
+	}
+	return ranks, nil
+}
+
+// bpe counts tokens with byte pair encoding over a ranked vocabulary
+type bpe struct {
+	ranks map[string]int
+}
+
+func (b *bpe) Count(text string) int {
+	count := 0
+	for i := 0; i < len(text); {
+		j := nextPiece(text, i)
+		count += b.countPiece(text[i:j])
+		i = j
+	}
+	return count
+}
+
+// countPiece merges the bytes of one pre-tokenized piece, always joining the
+// adjacent pair with the lowest rank (the leftmost one on ties), and returns
+// how many parts are left. Candidate merges wait in a heap and parts form a
+// linked list, so long pieces such as minified lines stay O(n log n).
+func (b *bpe) countPiece(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+	n := len(piece)
+	// Parts are named by where they start; next[i] is where the part after
+	// part i starts (n for the last one) and prev[i] the part before it
+	next := make([]int, n)
+	prev := make([]int, n)
+	merged := make([]bool, n)
+	var h mergeHeap
+	for i := 0; i < n; i++ {
+		next[i], prev[i] = i+1, i-1
+		if i+1 < n {
+			if rank, ok := b.ranks[piece[i:i+2]]; ok {
+				h = append(h, merge{rank, i, i + 2})
+			}
+		}
+	}
+	heap.Init(&h)
+
+	parts := n
+	for h.Len() > 0 {
+		m := heap.Pop(&h).(merge)
+		// Skip merges of parts that have changed since
+		right := next[m.start]
+		if merged[m.start] || right >= n || next[right] != m.end {
+			continue
+		}
+		merged[right] = true
+		next[m.start] = m.end
+		if m.end < n {
+			prev[m.end] = m.start
+		}
+		parts--
+
+		if left := prev[m.start]; left >= 0 {
+			if rank, ok := b.ranks[piece[left:m.end]]; ok {
+				heap.Push(&h, merge{rank, left, m.end})
+			}
+		}
+		if m.end < n {
+			if rank, ok := b.ranks[piece[m.start:next[m.end]]]; ok {
+				heap.Push(&h, merge{rank, m.start, next[m.end]})
+			}
+		}
+	}
+	return parts
+}
+
+// merge is a candidate join of the two parts spanning piece[start:end]
+type merge struct {
+	rank, start, end int
+}
+
+// mergeHeap orders merges by rank, then by position
+type mergeHeap []merge
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].start < h[j].start
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(merge)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
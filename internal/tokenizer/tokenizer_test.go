@@ -0,0 +1,121 @@
+package tokenizer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCL100KCount(t *testing.T) {
+	c, err := New(CL100K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expected counts come from OpenAI's tiktoken with cl100k_base
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"package main\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", 13},
+		{"I'm sure they'LL say 12345 times", 11},
+		{"日本語のテキスト", 8},
+		{"   \n\n    x  ", 4},
+	}
+	for _, c2 := range cases {
+		if got := c.Count(c2.text); got != c2.want {
+			t.Errorf("Count(%q) = %d; want %d", c2.text, got, c2.want)
+		}
+	}
+}
+
+func TestCharsCount(t *testing.T) {
+	c, err := New(Chars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"日本語のテキスト", 2},
+	}
+	for _, c2 := range cases {
+		if got := c.Count(c2.text); got != c2.want {
+			t.Errorf("Count(%q) = %d; want %d", c2.text, got, c2.want)
+		}
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	if _, err := New("p50k"); err == nil {
+		t.Error("New(\"p50k\") should fail")
+	}
+}
+
+// TestCountPieceMatchesNaive checks the heap merge against the plain one
+// that rescans every pair after each merge
+func TestCountPieceMatchesNaive(t *testing.T) {
+	c, err := New(CL100K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := c.(*bpe)
+	naive := func(piece string) int {
+		bounds := make([]int, len(piece)+1)
+		for i := range bounds {
+			bounds[i] = i
+		}
+		for len(bounds) > 2 {
+			best, at := -1, -1
+			for i := 0; i+2 < len(bounds); i++ {
+				if rank, ok := b.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (at < 0 || rank < best) {
+					best, at = rank, i
+				}
+			}
+			if at < 0 {
+				break
+			}
+			bounds = append(bounds[:at+1], bounds[at+2:]...)
+		}
+		return len(bounds) - 1
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	pieces := []string{"antidisestablishmentarianism", "aaaaaaaaaaaaaaaaaaaaaaa", "xQzJvKpW", "日本語のテキスト", "\x00\xff\xfe"}
+	for i := 0; i < 200; i++ {
+		piece := make([]byte, 1+rng.Intn(40))
+		for j := range piece {
+			piece[j] = "abcdeilnorst_XYZ09"[rng.Intn(18)]
+		}
+		pieces = append(pieces, string(piece))
+	}
+	for _, piece := range pieces {
+		if got, want := b.countPiece(piece), naive(piece); got != want {
+			t.Errorf("countPiece(%q) = %d; want %d", piece, got, want)
+		}
+	}
+}
+
+// TestCountLongPiece checks a minified-sized piece is counted quickly
+func TestCountLongPiece(t *testing.T) {
+	c, err := New(CL100K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	piece := strings.Repeat("abcdefghij", 50000)
+	start := time.Now()
+	if n := c.Count(piece); n == 0 {
+		t.Errorf("Count of a %d byte piece = 0", len(piece))
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("counting a %d byte piece took %v", len(piece), d)
+	}
+}
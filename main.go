@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 
-	"github.com/sky93/file-mapper/internal/listing"
+	"github.com/sky93/file-mapper/mapper"
 	"github.com/urfave/cli/v2"
 )
 
@@ -23,23 +27,80 @@ func main() {
 				Usage:   "Root path to scan",
 				Value:   ".", // default
 			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Config file with default flag values (default: .file-mapper.yaml, .file-mapper.yml or .file-mapper.toml in the scan root)",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Named profile from the config file to apply on top of its top-level values",
+			},
 			&cli.StringFlag{
 				Name:    "include",
 				Aliases: []string{"i"},
-				Usage:   "Comma-separated file patterns to include (e.g. '*.go,*.txt')",
+				Usage:   "Comma-separated file patterns to include (e.g. '*.go,internal/**/*.go'); patterns with a slash match the root-relative path",
 			},
 			&cli.StringFlag{
 				Name:    "exclude",
 				Aliases: []string{"e"},
-				Usage:   "Comma-separated directories/files to exclude (e.g. '.git,.idea,.env')",
+				Usage:   "Comma-separated directories/files to exclude (e.g. '.idea,.env,docs/generated'); patterns with a slash match the root-relative path",
 				// By default, we are ignoring hidden dirs. If you want to
 				// always exclude e.g. ".git,.idea,.env" add a default Value here.
 			},
+			&cli.StringFlag{
+				Name:  "include-regex",
+				Usage: "Only include files whose relative path matches this regular expression (e.g. '^(cmd|pkg)/.*\\.(go|proto)$')",
+			},
+			&cli.StringFlag{
+				Name:  "exclude-regex",
+				Usage: "Exclude paths matching this regular expression; directories are matched with a trailing slash (e.g. '_test\\.go$|(^|/)testdata/')",
+			},
+			&cli.StringFlag{
+				Name:  "ignore-file",
+				Usage: "Comma-separated extra ignore files in gitignore syntax, relative to the scan root (.filemapperignore is always read)",
+			},
+			&cli.IntFlag{
+				Name:    "max-depth",
+				Aliases: []string{"L"},
+				Usage:   "Only descend this many directory levels (like tree -L); deeper directories show their file count (0 = no limit)",
+			},
 			&cli.BoolFlag{
 				Name:    "git",
 				Aliases: []string{"g"},
 				Usage:   "Only list Git-tracked files",
 			},
+			&cli.BoolFlag{
+				Name:  "recurse-submodules",
+				Usage: "With --git or --rev, also list the files of submodules",
+			},
+			&cli.BoolFlag{
+				Name:  "git-status",
+				Usage: "Mark changed files with their git status (M, A, R, U, ??) and ignored ones with !!; directories holding changes get *",
+			},
+			&cli.BoolFlag{
+				Name:  "git-log",
+				Usage: "Show the last commit (hash, author, relative date and subject) of each file in the listing and content headers",
+			},
+			&cli.StringFlag{
+				Name:  "rev",
+				Usage: "List and read files from this Git commit, tag or branch instead of the working tree",
+			},
+			&cli.StringFlag{
+				Name:  "changed-since",
+				Usage: "Only list files that differ from this Git ref (e.g. main or HEAD~3), plus untracked ones",
+			},
+			&cli.BoolFlag{
+				Name:  "staged",
+				Usage: "Only list files with staged changes",
+			},
+			&cli.BoolFlag{
+				Name:  "unstaged",
+				Usage: "Only list files with unstaged changes, plus untracked ones",
+			},
+			&cli.BoolFlag{
+				Name:  "gitignore",
+				Usage: "Skip files ignored by .gitignore, .git/info/exclude and core.excludesFile (no git binary needed)",
+			},
 			&cli.BoolFlag{
 				Name:    "content",
 				Aliases: []string{"c"},
@@ -56,10 +117,37 @@ func main() {
 				Aliases: []string{"o"},
 				Usage:   "Output file path (if not provided, prints to stdout)",
 			},
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Usage:   "Output format: text, json, markdown or xml",
+				Value:   "text",
+			},
 			&cli.BoolFlag{
 				Name:  "flat",
 				Usage: "Show files in a flat list instead of the default tree",
 			},
+			&cli.StringFlag{
+				Name:  "sort",
+				Usage: "Order of siblings in the listing and content: name, size (largest first), mtime (newest first), lines (longest first) or ext",
+				Value: mapper.SortName,
+			},
+			&cli.BoolFlag{
+				Name:  "reverse",
+				Usage: "Reverse the sort order",
+			},
+			&cli.BoolFlag{
+				Name:  "dirs-first",
+				Usage: "List directories before files",
+			},
+			&cli.BoolFlag{
+				Name:  "natural",
+				Usage: "Sort names naturally, e.g. file2 before file10",
+			},
+			&cli.StringFlag{
+				Name:  "meta",
+				Usage: "Comma-separated columns to show after each name: size, lines, mtime, mode or all; directories show totals",
+			},
 			&cli.BoolFlag{
 				Name:  "line-numbers",
 				Usage: "Show line numbers for file content",
@@ -69,46 +157,210 @@ func main() {
 				Usage: "Show '----- CONTENT START -----' and '----- CONTENT END -----' markers",
 				Value: true, // default is to show them
 			},
+			&cli.BoolFlag{
+				Name:  "skeleton",
+				Usage: "For Go files, show only the package clause, imports, declarations, signatures and doc comments (implies --content)",
+			},
+			&cli.BoolFlag{
+				Name:  "strip-comments",
+				Usage: "Remove comments from file content (Go, C-family, Python, shell, YAML, SQL, HTML and more); string literals are left intact",
+			},
+			&cli.BoolFlag{
+				Name:  "collapse-blank-lines",
+				Usage: "Squeeze runs of blank lines in file content into one",
+			},
+			&cli.IntFlag{
+				Name:  "diff-context",
+				Usage: "With --changed-since, --staged or --unstaged, show only the changed lines of each file and this many lines around them (implies --content)",
+			},
+			&cli.BoolFlag{
+				Name:  "diff",
+				Usage: "With --changed-since, --staged or --unstaged, show each file's unified diff after its content (implies --content)",
+			},
+			&cli.StringFlag{
+				Name:  "max-file-size",
+				Usage: "List files larger than this (e.g. 500KB, 2MB) without their content",
+			},
+			&cli.IntFlag{
+				Name:  "max-lines",
+				Usage: "Show at most this many lines of each file, with a '... [N lines omitted] ...' marker (0 = no limit)",
+			},
+			&cli.IntFlag{
+				Name:  "head",
+				Usage: "Lines to keep from the start of each file",
+			},
+			&cli.IntFlag{
+				Name:  "tail",
+				Usage: "Lines to keep from the end of each file",
+			},
+			&cli.BoolFlag{
+				Name:  "tokens",
+				Usage: "Show token counts per file and for the whole output",
+			},
+			&cli.StringFlag{
+				Name:  "tokenizer",
+				Usage: "Tokenizer for --tokens: cl100k (offline BPE) or chars (chars/4 estimate)",
+				Value: mapper.TokenizerCL100K,
+			},
+			&cli.IntFlag{
+				Name:  "max-tokens",
+				Usage: "Drop files until the output fits in this many tokens (0 = no limit)",
+			},
+			&cli.StringFlag{
+				Name:  "priority",
+				Usage: "Order in which --max-tokens keeps files: comma-separated rules from readme, entry, tests-last, small, shallow",
+				Value: mapper.DefaultPriority,
+			},
+			&cli.StringFlag{
+				Name:  "secrets",
+				Usage: "What to do with credentials found in file content: redact, skip-file, fail or off",
+				Value: mapper.SecretsRedact,
+			},
+			&cli.StringFlag{
+				Name:  "split-size",
+				Usage: "Split --output into numbered parts of at most this size, e.g. 200KB, 5000lines or 8000tokens",
+			},
 		},
+		// Config file values fill in the flags not given on the command line
+		Before: applyConfigFile,
 		Action: func(ctx *cli.Context) error {
-			cfg := &listing.Config{
+			cfg := &mapper.Config{
 				RootPath:        ctx.String("path"),
 				Include:         ctx.String("include"),
 				Exclude:         ctx.String("exclude"),
+				IncludeRegex:    ctx.String("include-regex"),
+				ExcludeRegex:    ctx.String("exclude-regex"),
 				GitTrackedOnly:  ctx.Bool("git"),
+				GitStatus:       ctx.Bool("git-status"),
+				GitLog:          ctx.Bool("git-log"),
+				Rev:             ctx.String("rev"),
+				ChangedSince:    ctx.String("changed-since"),
+				Staged:          ctx.Bool("staged"),
+				Unstaged:        ctx.Bool("unstaged"),
+				UseGitignore:    ctx.Bool("gitignore"),
+				IgnoreFiles:     ctx.String("ignore-file"),
+				MaxDepth:        ctx.Int("max-depth"),
+				Format:          ctx.String("format"),
 				ShowTree:        !ctx.Bool("flat"), // default is tree
-				ShowContent:     ctx.Bool("content"),
+				Meta:            ctx.String("meta"),
+				Sort:            ctx.String("sort"),
+				Reverse:         ctx.Bool("reverse"),
+				DirsFirst:       ctx.Bool("dirs-first"),
+				NaturalSort:     ctx.Bool("natural"),
+				ShowContent:     ctx.Bool("content") || ctx.Bool("skeleton") || ctx.Bool("diff") || ctx.Int("diff-context") > 0,
 				SeparateContent: ctx.Bool("separate-content"),
 				Output:          ctx.String("output"),
 
-				ShowLineNumbers:   ctx.Bool("line-numbers"),
-				ShowHeaderFooters: ctx.Bool("header-footer"),
+				RecurseSubmodules:  ctx.Bool("recurse-submodules"),
+				ShowLineNumbers:    ctx.Bool("line-numbers"),
+				ShowHeaderFooters:  ctx.Bool("header-footer"),
+				Skeleton:           ctx.Bool("skeleton"),
+				StripComments:      ctx.Bool("strip-comments"),
+				CollapseBlankLines: ctx.Bool("collapse-blank-lines"),
+				DiffContext:        ctx.Int("diff-context"),
+				ShowDiff:           ctx.Bool("diff"),
+				MaxLines:           ctx.Int("max-lines"),
+				Head:               ctx.Int("head"),
+				Tail:               ctx.Int("tail"),
+				ShowTokens:         ctx.Bool("tokens"),
+				Tokenizer:          ctx.String("tokenizer"),
+				MaxTokens:          ctx.Int("max-tokens"),
+				Priority:           ctx.String("priority"),
+				Secrets:            ctx.String("secrets"),
 			}
 
-			result, err := listing.Run(cfg)
-			if err != nil {
-				return err
-			}
-
-			if cfg.Output != "" {
-				f, err := os.Create(cfg.Output)
+			if size := ctx.String("max-file-size"); size != "" {
+				n, err := mapper.ParseByteSize(size)
 				if err != nil {
 					return err
 				}
-				defer f.Close()
-				if _, err = f.WriteString(result); err != nil {
-					return err
+				cfg.MaxFileSize = n
+			}
+
+			// Summarize any credentials found once the run is over
+			var findings []mapper.SecretFinding
+			cfg.OnSecret = func(f mapper.SecretFinding) { findings = append(findings, f) }
+			defer func() { reportSecrets(cfg.Secrets, findings) }()
+
+			// Stream straight into the output file (or stdout)
+			if cfg.Output == "" {
+				if ctx.String("split-size") != "" {
+					return fmt.Errorf("--split-size needs --output to name the parts")
 				}
-				log.Printf("Output written to %s\n", cfg.Output)
-			} else {
-				os.Stdout.WriteString(result)
+				return mapper.RunTo(ctx.Context, cfg, os.Stdout)
+			}
+
+			if ctx.String("split-size") != "" {
+				return writeParts(ctx.Context, cfg, ctx.String("split-size"))
+			}
+
+			f, err := os.Create(cfg.Output)
+			if err != nil {
+				return err
+			}
+			err = mapper.RunTo(ctx.Context, cfg, f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				// Don't leave a truncated dump behind
+				os.Remove(cfg.Output)
+				return err
 			}
+			log.Printf("Output written to %s\n", cfg.Output)
 
 			return nil
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	// Ctrl-C cancels the walk and stops streaming between files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// writeParts writes the output as numbered parts next to cfg.Output
+// ("out.txt" becomes "out.001.txt", "out.002.txt", ...)
+func writeParts(ctx context.Context, cfg *mapper.Config, splitSize string) error {
+	size, err := mapper.ParseSplitSize(splitSize)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	_, err = mapper.RunSplit(ctx, cfg, size, func(part int) (io.WriteCloser, error) {
+		path := mapper.ChunkPath(cfg.Output, part)
+		paths = append(paths, path)
+		return os.Create(path)
+	})
+	if err != nil {
+		// Don't leave a truncated set of parts behind
+		for _, path := range paths {
+			os.Remove(path)
+		}
+		return err
+	}
+	log.Printf("Output written to %s ... %s (%d parts)\n", paths[0], paths[len(paths)-1], len(paths))
+	return nil
+}
+
+// reportSecrets prints a summary of the secrets found to stderr
+func reportSecrets(mode string, findings []mapper.SecretFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	switch mode {
+	case mapper.SecretsRedact:
+		log.Printf("Redacted %d secret(s):\n", len(findings))
+	case mapper.SecretsSkipFile:
+		log.Printf("Skipped files containing %d secret(s):\n", len(findings))
+	default:
+		log.Printf("Found %d secret(s):\n", len(findings))
+	}
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  %s:%d: %s\n", f.Path, f.Line, f.Kind)
+	}
+}
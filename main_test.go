@@ -12,7 +12,7 @@ import (
 func TestMainCLI(t *testing.T) {
 	// Build the CLI binary
 	binPath := filepath.Join(t.TempDir(), "test-file-mapper")
-	cmdBuild := exec.Command("go", "build", "-o", binPath, "main.go")
+	cmdBuild := exec.Command("go", "build", "-o", binPath, ".")
 	if out, err := cmdBuild.CombinedOutput(); err != nil {
 		t.Fatalf("Build failed: %v\n%s", err, string(out))
 	}
@@ -37,7 +37,7 @@ func TestMainCLI(t *testing.T) {
 func TestMainCLI_FlatList(t *testing.T) {
 	// Build CLI
 	binPath := filepath.Join(t.TempDir(), "test-file-mapper")
-	cmdBuild := exec.Command("go", "build", "-o", binPath, "main.go")
+	cmdBuild := exec.Command("go", "build", "-o", binPath, ".")
 	if out, err := cmdBuild.CombinedOutput(); err != nil {
 		t.Fatalf("Build failed: %v\n%s", err, string(out))
 	}
@@ -65,3 +65,34 @@ func TestMainCLI_FlatList(t *testing.T) {
 		t.Errorf("Expected 'hello.txt' in the flat listing output, got:\n%s", outStr)
 	}
 }
+
+func TestMainCLI_OutputInsideRoot(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "test-file-mapper")
+	cmdBuild := exec.Command("go", "build", "-o", binPath, ".")
+	if out, err := cmdBuild.CombinedOutput(); err != nil {
+		t.Fatalf("Build failed: %v\n%s", err, string(out))
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "hello.txt"), []byte("hello content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output file is created inside the scanned root; the second run
+	// also finds the first run's output there
+	for run := 1; run <= 2; run++ {
+		cmdRun := exec.Command(binPath, "-c", "-o", "out.txt")
+		cmdRun.Dir = tmpDir
+		if out, err := cmdRun.CombinedOutput(); err != nil {
+			t.Fatalf("Command failed: %v\n%s", err, string(out))
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "out.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "hello content") || strings.Contains(string(data), "out.txt") {
+			t.Errorf("run %d: expected hello.txt and not out.txt itself, got:\n%s", run, data)
+		}
+	}
+}
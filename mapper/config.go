@@ -0,0 +1,74 @@
+package mapper
+
+// Output formats accepted by Config.Format (empty means FormatText)
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatXML      = "xml"
+)
+
+// Config stores all user options. The CLI fills it from flags; library users
+// set the fields directly.
+type Config struct {
+	RootPath       string
+	Include        string
+	Exclude        string
+	IncludeRegex   string // matched against the relative file path
+	ExcludeRegex   string // matched against the relative path ("dir/" for directories)
+	GitTrackedOnly bool
+	Rev            string // if set, list and read files from this Git revision instead of the working tree
+	ChangedSince   string // if set, only list files that differ from this Git ref (and untracked ones)
+	Staged         bool   // only list files with staged changes
+	Unstaged       bool   // only list files with unstaged changes (and untracked ones)
+	UseGitignore   bool   // honor .gitignore, info/exclude and core.excludesFile
+	IgnoreFiles    string // comma-separated extra gitignore-style files (besides .filemapperignore)
+	MaxDepth       int    // if > 0, directories this deep are listed but not expanded
+
+	// Git integration
+	RecurseSubmodules bool // with GitTrackedOnly or Rev, also list the files of submodules
+	GitStatus         bool // annotate changed and ignored entries with their git status
+	GitLog            bool // annotate files with the last commit that changed them
+
+	// Output style
+	Format          string // one of the Format* constants
+	ShowTree        bool   // tree or flat
+	Meta            string // comma-separated metadata columns shown after names (see MetaSize etc.)
+	Sort            string // one of the Sort* constants
+	Reverse         bool   // reverse the sort order
+	DirsFirst       bool   // list directories before files
+	NaturalSort     bool   // compare digits in names by value ("file2" before "file10")
+	ShowContent     bool   // whether to include file content at all
+	SeparateContent bool   // if true, print the tree/flat list first, then content after
+
+	Output string // optional file path for output; Walk leaves it out (and RunSplit its parts)
+
+	// Content details
+	ShowLineNumbers    bool
+	ShowHeaderFooters  bool
+	Skeleton           bool // for Go files, show only declarations and signatures
+	StripComments      bool // remove comments from files in known languages
+	CollapseBlankLines bool // squeeze runs of blank lines into one
+	DiffContext        int  // with changed files only, if > 0, show just the changed lines and this many around them
+	ShowDiff           bool // with changed files only, show each file's unified diff after its content
+
+	// Size limits
+	MaxFileSize int64 // if > 0, files larger than this many bytes are listed without content
+	MaxLines    int   // if > 0, show at most this many lines of each file
+	Head        int   // lines to keep from the start of each file
+	Tail        int   // lines to keep from the end of each file
+
+	// Token counts
+	ShowTokens bool   // annotate files with token counts and print a total
+	Tokenizer  string // one of the Tokenizer* constants
+	MaxTokens  int    // if > 0, drop files until the output fits this many tokens
+	Priority   string // comma-separated packing rules for MaxTokens (see DefaultPriority)
+
+	// Credentials in file content
+	Secrets  string              // one of the Secrets* constants; redacts by default, SecretsOff disables scanning
+	OnSecret func(SecretFinding) // called once per secret found, before anything is written
+
+	// Renderer, if set, replaces the built-in renderer chosen by Format.
+	// It's only available to library users.
+	Renderer Renderer
+}
@@ -0,0 +1,300 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OpenContent opens the content of a file found by Walk the way the built-in
+// renderers show it: read from cfg.Rev's tree if set, transformed by the
+// content options (such as cfg.StripComments and the line limits) and with
+// secrets redacted for SecretsRedact. Custom renderers should read files
+// through it rather than from Entry.Path. Files over cfg.MaxFileSize aren't
+// read at all; a marker stands in for their content. Directories are
+// rejected, since empty ones show up as tree leaves.
+func OpenContent(cfg *Config, e Entry) (io.ReadCloser, error) {
+	return openContent(cfg, e)
+}
+
+// openContent implements OpenContent. All renderers go through here, so the
+// content is streamed rather than read into memory (except when it has to be
+// transformed or redacted first).
+func openContent(cfg *Config, e Entry) (io.ReadCloser, error) {
+	r, data, _, err := loadContent(cfg, e)
+	if err != nil || r != nil {
+		return r, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// openContentStats is openContent for renderers that need the stats of the
+// content before streaming it. Content that is in memory anyway is scanned
+// there rather than read again.
+func openContentStats(cfg *Config, e Entry) (io.ReadCloser, *contentStats, error) {
+	r, data, total, err := loadContent(cfg, e)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r == nil {
+		stats, err := entryStats(e, bytes.NewReader(data), total)
+		if err != nil {
+			return nil, nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), stats, nil
+	}
+
+	// A file shown as it is gets a pass of its own
+	stats, err := entryStats(e, r, total)
+	r.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err = openContent(cfg, e)
+	return r, stats, err
+}
+
+// loadContent returns e's content as renderers show it: the open file when
+// it's shown as it is, or else the content in memory. total is the line
+// count of content the line limits cut, and 0 otherwise.
+func loadContent(cfg *Config, e Entry) (io.ReadCloser, []byte, int, error) {
+	path := e.Path
+	f, info, err := openFile(e.run, path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if info.IsDir() {
+		if f != nil {
+			f.Close()
+		}
+		return nil, nil, 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	lr, ranged := e.run.lines(path)
+	var data []byte
+	total := 0
+	switch {
+	case tooLarge(cfg, info):
+		f.Close()
+		data = skippedContent(cfg, info)
+	case hasTransforms(cfg, e) || needsRedaction(cfg, e):
+		// Transforms need the whole file, e.g. since private keys span lines
+		data, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		data, total = limitLines(cfg, rewriteContent(cfg, e, data))
+		if secretsMode(cfg) == SecretsRedact {
+			matches, ok := e.run.secretMatches(path)
+			if !ok {
+				matches = findSecrets(path, data)
+			}
+			data = redactSecrets(data, matches)
+		}
+	case !ranged:
+		return f, nil, 0, nil
+	}
+
+	// Only part of a file split across several RunSplit parts, which
+	// doesn't show the whole file either way
+	if ranged {
+		r := f
+		if data != nil {
+			r = io.NopCloser(bytes.NewReader(data))
+		}
+		data, err = readLineRange(r, lr)
+		r.Close()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		total = 0
+	}
+	return nil, data, total, nil
+}
+
+// needsRedaction reports whether e has to be read into memory to redact its
+// secrets. Walk already found those of the files it checked, most of which
+// have none.
+func needsRedaction(cfg *Config, e Entry) bool {
+	if secretsMode(cfg) != SecretsRedact {
+		return false
+	}
+	matches, ok := e.run.secretMatches(e.Path)
+	return !ok || len(matches) > 0
+}
+
+// hasTransforms reports whether transformContent changes e's content
+func hasTransforms(cfg *Config, e Entry) bool {
+	return hasDiffContext(cfg, e) || hasSkeleton(cfg, e.Path) || cfg.StripComments || cfg.CollapseBlankLines || hasLineLimits(cfg)
+}
+
+// transformContent applies the content options that rewrite a file, such
+// as Config.DiffContext, Config.Skeleton, Config.StripComments and the line
+// limits. Secrets are scanned for in the result.
+func transformContent(cfg *Config, e Entry, data []byte) []byte {
+	data, _ = limitLines(cfg, rewriteContent(cfg, e, data))
+	return data
+}
+
+// rewriteContent is transformContent without the line limits
+func rewriteContent(cfg *Config, e Entry, data []byte) []byte {
+	path := e.Path
+	// Changed lines are numbered as in the file, so this comes first
+	if hasDiffContext(cfg, e) {
+		data = diffContext(data, e.run.change(path).changed, cfg.DiffContext)
+	}
+	if hasSkeleton(cfg, path) {
+		// Files that don't parse are shown as they are
+		if skeleton, err := goSkeleton(data); err == nil {
+			data = skeleton
+		}
+	}
+	if cfg.StripComments {
+		if syn := commentSyntaxes[detectLanguage(path, data)]; syn != nil {
+			data = stripComments(data, syn)
+		}
+	}
+	if cfg.CollapseBlankLines {
+		data = collapseBlankLines(data)
+	}
+	return data
+}
+
+// contentStats holds what renderers need to know about a file before they
+// start streaming it
+type contentStats struct {
+	Lines            int    // line count, as strings.Split(content, "\n") would give
+	Total            int    // line count before the line limits cut the content, or 0
+	LongestBackticks int    // longest run of consecutive backticks
+	HasMarkup        bool   // contains '<', '>' or '&'
+	Head             []byte // the first line (capped), for shebang detection
+}
+
+// maxHeadLen caps how much of the first line contentStats keeps
+const maxHeadLen = 256
+
+// scanContent makes a single streaming pass over a file to collect its stats
+func scanContent(cfg *Config, e Entry) (*contentStats, error) {
+	r, data, total, err := loadContent(cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = io.NopCloser(bytes.NewReader(data))
+	}
+	defer r.Close()
+	return entryStats(e, r, total)
+}
+
+// entryStats collects the stats of e's content read from r. total is the
+// line count loadContent gives for content the line limits cut.
+func entryStats(e Entry, r io.Reader, total int) (*contentStats, error) {
+	stats, err := readStats(r)
+	if err != nil {
+		return nil, err
+	}
+	stats.Total = total
+	return stats, nil
+}
+
+// readStats collects the stats of content as it's read
+func readStats(r io.Reader) (*contentStats, error) {
+	stats := &contentStats{Lines: 1}
+	inHead := true
+	run := 0
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			switch b {
+			case '\n':
+				stats.Lines++
+				inHead = false
+			case '<', '>', '&':
+				stats.HasMarkup = true
+			}
+
+			if b == '`' {
+				run++
+				if run > stats.LongestBackticks {
+					stats.LongestBackticks = run
+				}
+			} else {
+				run = 0
+			}
+
+			if inHead && len(stats.Head) < maxHeadLen {
+				stats.Head = append(stats.Head, b)
+			}
+		}
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// eachLine calls fn for every line of r, including its trailing newline.
+// Like strings.Split(content, "\n"), the final line is always reported,
+// even when it's empty because the content ends with a newline.
+func eachLine(r io.Reader, fn func(line string)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			fn(line)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(line)
+	}
+}
+
+// writeLines streams r line by line; prefix is written before every line and
+// line numbers, starting at first, are added if cfg.ShowLineNumbers is set.
+// Every line, including the last, is terminated with a newline.
+func writeLines(w *bufio.Writer, cfg *Config, r io.Reader, prefix string, first int) error {
+	n := first - 1
+	return eachLine(r, func(line string) {
+		n++
+		w.WriteString(prefix)
+		if cfg.ShowLineNumbers {
+			fmt.Fprintf(w, "%4d: ", n)
+		}
+		w.WriteString(strings.TrimSuffix(line, "\n"))
+		w.WriteByte('\n')
+	})
+}
+
+// copyContent streams r to w verbatim and makes sure the output ends with a
+// newline
+func copyContent(w *bufio.Writer, r io.Reader) error {
+	lw := &lastByteWriter{w: w}
+	if _, err := io.Copy(lw, r); err != nil {
+		return err
+	}
+	if lw.last != '\n' {
+		w.WriteByte('\n')
+	}
+	return nil
+}
+
+// lastByteWriter remembers the last byte written through it
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		l.last = p[len(p)-1]
+	}
+	return l.w.Write(p)
+}
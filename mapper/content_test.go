@@ -0,0 +1,81 @@
+package mapper
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEachLine(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"", []string{""}},
+		{"a", []string{"a"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n", []string{"a\n", ""}},
+	}
+	for _, c := range cases {
+		var got []string
+		if err := eachLine(strings.NewReader(c.input), func(line string) { got = append(got, line) }); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("eachLine(%q) = %q; want %q", c.input, got, c.want)
+		}
+		// Same line count as strings.Split
+		if len(got) != len(strings.Split(c.input, "\n")) {
+			t.Errorf("eachLine(%q) yielded %d lines; strings.Split gives %d", c.input, len(got), len(strings.Split(c.input, "\n")))
+		}
+	}
+}
+
+func TestScanContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script")
+	os.WriteFile(path, []byte("#!/bin/sh\necho '````' && true\n"), 0644)
+
+	stats, err := scanContent(&Config{}, Entry{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Lines != 3 {
+		t.Errorf("Expected 3 lines, got %d", stats.Lines)
+	}
+	if stats.LongestBackticks != 4 {
+		t.Errorf("Expected a backtick run of 4, got %d", stats.LongestBackticks)
+	}
+	if !stats.HasMarkup {
+		t.Error("Expected '&' to count as markup")
+	}
+	if string(stats.Head) != "#!/bin/sh" {
+		t.Errorf("Unexpected head %q", stats.Head)
+	}
+
+	if _, err := scanContent(&Config{}, Entry{Path: filepath.Dir(path)}); err == nil {
+		t.Error("Expected an error when scanning a directory")
+	}
+}
+
+func TestCopyContent(t *testing.T) {
+	for input, want := range map[string]string{"": "\n", "a": "a\n", "a\n": "a\n"} {
+		got := render(t, func(w *bufio.Writer) error {
+			return copyContent(w, strings.NewReader(input))
+		})
+		if got != want {
+			t.Errorf("copyContent(%q) = %q; want %q", input, got, want)
+		}
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	cfg := &Config{ShowLineNumbers: true}
+	got := render(t, func(w *bufio.Writer) error {
+		return writeLines(w, cfg, strings.NewReader("a\nb"), "│   ", 1)
+	})
+	if got != "│      1: a\n│      2: b\n" {
+		t.Errorf("writeLines = %q", got)
+	}
+}
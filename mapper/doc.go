@@ -0,0 +1,20 @@
+// Package mapper maps a project tree and its file contents, the engine
+// behind the file-mapper CLI.
+//
+// A run has two steps. Walk applies the filters in a Config (include and
+// exclude patterns, ignore files, Git tracking, ...) and returns the
+// accepted entries. A Renderer then streams those entries to an io.Writer.
+// RunTo chains both, using the built-in renderer for Config.Format unless
+// Config.Renderer supplies a custom one:
+//
+//	cfg := &mapper.Config{
+//		RootPath:        ".",
+//		Include:         "*.go",
+//		ShowTree:        true,
+//		ShowContent:     true,
+//		SeparateContent: true,
+//	}
+//	if err := mapper.RunTo(ctx, cfg, os.Stdout); err != nil {
+//		log.Fatal(err)
+//	}
+package mapper
@@ -0,0 +1,80 @@
+package mapper_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/sky93/file-mapper/mapper"
+)
+
+func ExampleRunTo() {
+	root, _ := os.MkdirTemp("", "mapper-example")
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "cmd"), 0755)
+	os.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(root, "go.mod"), []byte("module demo\n"), 0644)
+
+	cfg := &mapper.Config{
+		RootPath: root,
+		Format:   mapper.FormatMarkdown,
+		ShowTree: true,
+	}
+	if err := mapper.RunTo(context.Background(), cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// ```
+	// ├── cmd
+	// │   └── main.go
+	// └── go.mod
+	// ```
+}
+
+func ExampleWalk() {
+	root, _ := os.MkdirTemp("", "mapper-example")
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "cmd"), 0755)
+	os.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(root, "README.md"), []byte("# demo\n"), 0644)
+
+	entries, err := mapper.Walk(context.Background(), &mapper.Config{RootPath: root})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range entries {
+		fmt.Println(e.RelPath, e.IsDir)
+	}
+	// Output:
+	// README.md false
+	// cmd true
+	// cmd/main.go false
+}
+
+func ExampleRendererFunc() {
+	root, _ := os.MkdirTemp("", "mapper-example")
+	defer os.RemoveAll(root)
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("hello, world"), 0644)
+
+	// A custom renderer listing each file with its size
+	sizes := mapper.RendererFunc(func(ctx context.Context, w io.Writer, cfg *mapper.Config, entries []mapper.Entry) error {
+		for _, e := range entries {
+			if !e.IsDir {
+				fmt.Fprintf(w, "%s\t%d\n", e.RelPath, e.Info.Size())
+			}
+		}
+		return nil
+	})
+
+	cfg := &mapper.Config{RootPath: root, Renderer: sizes}
+	if err := mapper.RunTo(context.Background(), cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// a.txt	5
+	// b.txt	12
+}
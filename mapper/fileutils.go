@@ -0,0 +1,172 @@
+package mapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// isBinary does a naive check if a file is binary by scanning the first 8KB for null bytes.
+func isBinary(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true // if we can't open, assume binary
+	}
+	defer f.Close()
+
+	buf := make([]byte, 8000)
+	n, err := f.Read(buf)
+	if err != nil && err != io.EOF {
+		return true
+	}
+	return isBinaryData(buf[:n])
+}
+
+// isBinaryFile is isBinary for a walked file, which may come from Config.Rev
+func isBinaryFile(run *walkRun, path string) bool {
+	tree := run.revTree()
+	if tree == nil {
+		return isBinary(path)
+	}
+	r, err := tree.open(path)
+	if err != nil {
+		return true
+	}
+	defer r.Close()
+	buf := make([]byte, 8000)
+	n, _ := io.ReadFull(r, buf)
+	return isBinaryData(buf[:n])
+}
+
+// openFile opens a walked file, reading it from Config.Rev's tree if the
+// run has one
+func openFile(run *walkRun, path string) (io.ReadCloser, fs.FileInfo, error) {
+	if tree := run.revTree(); tree != nil {
+		info, err := tree.stat(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if info.IsDir() {
+			return nil, info, nil
+		}
+		r, err := tree.open(path)
+		return r, info, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// statFile is os.Stat for walked files, which may come from Config.Rev
+func statFile(run *walkRun, path string) (fs.FileInfo, error) {
+	if tree := run.revTree(); tree != nil {
+		return tree.stat(path)
+	}
+	return os.Stat(path)
+}
+
+// readFile is os.ReadFile for walked files, which may come from Config.Rev
+func readFile(run *walkRun, path string) ([]byte, error) {
+	r, _, err := openFile(run, path)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func isBinaryData(buf []byte) bool {
+	if bytes.Contains(buf, []byte{0}) {
+		return true
+	}
+	return false
+}
+
+// isHidden checks if the file/dir starts with a dot relative to the root
+func isHidden(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false // fallback
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, p := range parts {
+		if strings.HasPrefix(p, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldExclude checks if the path or directory name matches the exclude list
+func shouldExclude(path string, info os.FileInfo, excludePatterns []string, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = info.Name()
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range excludePatterns {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyPattern checks if the root-relative, slash-separated path matches
+// any of the include patterns
+func matchesAnyPattern(rel string, patterns []string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a user-supplied glob against a root-relative path.
+// Patterns containing a slash are matched against the whole path (with "**"
+// support), patterns without one against the base name only.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if strings.Contains(pattern, "/") {
+		return matchGlob(strings.TrimPrefix(pattern, "/"), rel)
+	}
+	base := path.Base(rel)
+	matched, err := path.Match(pattern, base)
+	return (err == nil && matched) || base == pattern
+}
+
+// compileRegex compiles an optional regular expression; an empty string yields nil
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// regexSubject is the string include/exclude regexes are matched against:
+// the slash-separated relative path, with a trailing slash for directories
+func regexSubject(rel string, isDir bool) string {
+	rel = filepath.ToSlash(rel)
+	if isDir {
+		rel += "/"
+	}
+	return rel
+}
@@ -1,8 +1,9 @@
-package listing
+package mapper
 
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -85,6 +86,33 @@ func TestShouldExclude(t *testing.T) {
 	}
 }
 
+func TestShouldExcludePaths(t *testing.T) {
+	root := filepath.FromSlash("/fake/root")
+	exPatterns := []string{"docs/generated", "**/testdata/*.golden", "/vendor/"}
+
+	cases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"docs/generated", true, true},
+		{"docs/handwritten", true, false},
+		{"generated", true, false},
+		{"pkg/a/testdata/out.golden", false, true},
+		{"testdata/out.golden", false, true},
+		{"pkg/a/testdata/in.txt", false, false},
+		{"vendor", true, true},
+		{"pkg/vendor", true, false},
+	}
+	for _, c := range cases {
+		p := filepath.Join(root, filepath.FromSlash(c.rel))
+		info := mockFileInfo{name: filepath.Base(p), isDirVal: c.isDir}
+		if got := shouldExclude(p, info, exPatterns, root); got != c.want {
+			t.Errorf("shouldExclude(%q) = %v; want %v", c.rel, got, c.want)
+		}
+	}
+}
+
 func TestMatchesAnyPattern(t *testing.T) {
 	patterns := []string{"*.go", "*.md"}
 	if !matchesAnyPattern("main.go", patterns) {
@@ -93,6 +121,30 @@ func TestMatchesAnyPattern(t *testing.T) {
 	if matchesAnyPattern("main.py", patterns) {
 		t.Error("Expected main.py NOT to match *.go or *.md")
 	}
+	// Patterns without a slash fall back to the base name
+	if !matchesAnyPattern("internal/listing/print.go", patterns) {
+		t.Error("Expected internal/listing/print.go to match *.go")
+	}
+}
+
+func TestMatchesAnyPatternPaths(t *testing.T) {
+	patterns := []string{"internal/**/*.go", "cmd/*/main.go"}
+	cases := []struct {
+		rel  string
+		want bool
+	}{
+		{"internal/listing/print.go", true},
+		{"internal/print.go", true},
+		{"internal/listing/README.md", false},
+		{"main.go", false},
+		{"cmd/tool/main.go", true},
+		{"cmd/tool/sub/main.go", false},
+	}
+	for _, c := range cases {
+		if got := matchesAnyPattern(c.rel, patterns); got != c.want {
+			t.Errorf("matchesAnyPattern(%q) = %v; want %v", c.rel, got, c.want)
+		}
+	}
 }
 
 // mockFileInfo is a tiny test helper
@@ -109,3 +161,22 @@ func (m mockFileInfo) Mode() os.FileMode { return 0644 }
 func (m mockFileInfo) ModTime() time.Time { return time.Now() }
 func (m mockFileInfo) IsDir() bool        { return m.isDirVal }
 func (m mockFileInfo) Sys() interface{}   { return nil }
+
+func TestCompileRegex(t *testing.T) {
+	if re, err := compileRegex(""); re != nil || err != nil {
+		t.Errorf("Expected nil regex for empty input, got %v, %v", re, err)
+	}
+	if _, err := compileRegex("("); err == nil {
+		t.Error("Expected an error for an invalid regex")
+	}
+	re, err := compileRegex(`(^|/)testdata/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString(regexSubject(filepath.Join("pkg", "testdata"), true)) {
+		t.Error("Expected directory subject to carry a trailing slash")
+	}
+	if re.MatchString(regexSubject("testdata.go", false)) {
+		t.Error("Expected file subject without a trailing slash")
+	}
+}
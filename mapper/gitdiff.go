@@ -0,0 +1,320 @@
+package mapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitChanges holds the files that differ from the base chosen by
+// Config.ChangedSince, Config.Staged or Config.Unstaged
+type gitChanges struct {
+	files map[string]*fileChange // by path joined with the root, like Entry.Path
+}
+
+// fileChange is a changed file and its part of the diff
+type fileChange struct {
+	rel       string // slash-separated, relative to the root
+	untracked bool   // new and not yet added, so git diff doesn't show it
+	diff      []byte // from "--- a/..." on; only read for DiffContext and ShowDiff
+	changed   []int  // sorted new-side lines that were added, or next to removed ones
+}
+
+// diffArgs returns the git diff arguments selecting cfg's changes, or nil
+// if cfg doesn't restrict the listing to changed files
+func diffArgs(cfg *Config) ([]string, error) {
+	modes := 0
+	for _, set := range []bool{cfg.ChangedSince != "", cfg.Staged, cfg.Unstaged} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return nil, fmt.Errorf("only one of changed-since, staged and unstaged can be used")
+	}
+	if cfg.Rev != "" && (cfg.Staged || cfg.Unstaged) {
+		return nil, fmt.Errorf("staged and unstaged changes can't be listed for a revision")
+	}
+
+	switch {
+	case cfg.Staged:
+		return []string{"diff", "--cached"}, nil
+	case cfg.Unstaged:
+		return []string{"diff"}, nil
+	case cfg.ChangedSince != "" && cfg.Rev != "":
+		return []string{"diff", cfg.ChangedSince, cfg.Rev}, nil
+	case cfg.ChangedSince != "":
+		return []string{"diff", cfg.ChangedSince}, nil
+	}
+	return nil, nil
+}
+
+// loadGitChanges lists the files changed below cfg.RootPath, and reads their
+// diff if cfg shows it or limits content to the changed lines. Deleted files
+// are left out since there's nothing left to show.
+func loadGitChanges(ctx context.Context, cfg *Config) (*gitChanges, error) {
+	args, err := diffArgs(cfg)
+	if args == nil || err != nil {
+		return nil, err
+	}
+	root := cfg.RootPath
+
+	// core.quotePath=false keeps non-ASCII names readable in the patch;
+	// the prefixes are fixed in case diff.noprefix or the like is set
+	args = append([]string{"-c", "core.quotePath=false"}, args...)
+	args = append(args, "--relative", "--diff-filter=d", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/")
+	out, err := gitOutput(ctx, root, append(args, "--name-only", "-z", "--", ".")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %v", err)
+	}
+
+	c := &gitChanges{files: make(map[string]*fileChange)}
+	for _, rel := range strings.Split(string(out), "\x00") {
+		if rel != "" {
+			c.add(root, &fileChange{rel: rel})
+		}
+	}
+
+	// New files that were never added differ from any base but the index
+	if cfg.Rev == "" && !cfg.Staged {
+		out, err := gitOutput(ctx, root, "ls-files", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list untracked files: %v", err)
+		}
+		for _, rel := range strings.Split(string(out), "\x00") {
+			if rel != "" {
+				c.add(root, &fileChange{rel: rel, untracked: true})
+			}
+		}
+	}
+
+	if cfg.DiffContext > 0 || cfg.ShowDiff {
+		context := 3
+		if cfg.DiffContext > 0 {
+			context = cfg.DiffContext
+		}
+		out, err := gitOutput(ctx, root, append(args, "-U"+strconv.Itoa(context), "--", ".")...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the diff: %v", err)
+		}
+		for _, file := range splitPatch(out) {
+			if f := c.files[filepath.Join(root, filepath.FromSlash(file.rel))]; f != nil {
+				f.diff, f.changed = file.diff, file.changed
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *gitChanges) add(root string, f *fileChange) {
+	c.files[filepath.Join(root, filepath.FromSlash(f.rel))] = f
+}
+
+// file returns the change to a walked file, or nil
+func (c *gitChanges) file(path string) *fileChange {
+	if c == nil {
+		return nil
+	}
+	return c.files[path]
+}
+
+// splitPatch splits a unified diff into one fileChange per file with a
+// textual diff; mode changes and binary files have no "+++" line and are
+// skipped
+func splitPatch(patch []byte) []*fileChange {
+	var files []*fileChange
+	for _, part := range bytes.Split(patch, []byte("\ndiff --git ")) {
+		start := bytes.Index(part, []byte("\n--- "))
+		if start < 0 {
+			continue
+		}
+		diff := part[start+1:]
+		if !bytes.HasSuffix(diff, []byte("\n")) {
+			diff = append(diff, '\n')
+		}
+
+		var f *fileChange
+		newLine := 0
+		for _, line := range strings.SplitAfter(string(diff), "\n") {
+			switch {
+			case f == nil:
+				if strings.HasPrefix(line, "+++ ") {
+					f = &fileChange{rel: patchPath(strings.TrimSuffix(line[4:], "\n")), diff: diff}
+				}
+			case strings.HasPrefix(line, "@@ "):
+				newLine = hunkStart(line)
+			case strings.HasPrefix(line, "+"):
+				f.markChanged(newLine)
+				newLine++
+			case strings.HasPrefix(line, "-"):
+				// Removed lines are shown next to the line that follows them
+				f.markChanged(newLine)
+			case strings.HasPrefix(line, " "):
+				newLine++
+			}
+		}
+		if f != nil && f.rel != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// markChanged adds line to f.changed, which is built in order
+func (f *fileChange) markChanged(line int) {
+	if n := len(f.changed); n == 0 || f.changed[n-1] != line {
+		f.changed = append(f.changed, line)
+	}
+}
+
+// patchPath returns the path in a "+++ b/<path>" line, unquoting it if git
+// quoted it for special characters
+func patchPath(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	return strings.TrimPrefix(s, "b/")
+}
+
+// hunkStart returns the first new-side line of a "@@ -a,b +c,d @@" header
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.SplitN(strings.TrimPrefix(fields[2], "+"), ",", 2)[0])
+	return n
+}
+
+// fileDiff returns the unified diff of a changed file for Config.ShowDiff,
+// with secrets redacted as in its content
+func fileDiff(cfg *Config, e Entry) []byte {
+	diff := rawDiff(cfg, e)
+	if diff != nil && secretsMode(cfg) == SecretsRedact {
+		diff = redactSecrets(diff, findSecrets(e.Path, diff))
+	}
+	return diff
+}
+
+// rawDiff is fileDiff before redaction. Untracked files are shown as wholly
+// added.
+func rawDiff(cfg *Config, e Entry) []byte {
+	f := e.run.change(e.Path)
+	if f == nil || !cfg.ShowDiff {
+		return nil
+	}
+	diff := f.diff
+	if f.untracked {
+		content, err := readFile(e.run, e.Path)
+		if err != nil || len(content) == 0 {
+			return nil
+		}
+		diff = addedDiff(f.rel, content)
+	}
+	return diff
+}
+
+// addedDiff returns a diff adding content as a new file
+func addedDiff(rel string, content []byte) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", rel, len(lines))
+	for _, line := range lines {
+		buf.WriteByte('+')
+		buf.Write(line)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+	return buf.Bytes()
+}
+
+// hasDiffContext reports whether diffContext applies to e
+func hasDiffContext(cfg *Config, e Entry) bool {
+	f := e.run.change(e.Path)
+	return cfg.DiffContext > 0 && f != nil && !f.untracked
+}
+
+// diffContext keeps the changed lines of content and n lines around each,
+// replacing the rest with "... [N lines omitted] ..." markers
+func diffContext(content []byte, changed []int, n int) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // content ends with a newline
+	}
+
+	keep := make([]bool, len(lines))
+	for _, c := range changed {
+		for i := c - 1 - n; i <= c-1+n; i++ {
+			if i >= 0 && i < len(lines) {
+				keep[i] = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	omitted := 0
+	for i, line := range lines {
+		if !keep[i] {
+			omitted++
+			continue
+		}
+		if omitted > 0 {
+			fmt.Fprintf(&buf, "... [%d lines omitted] ...\n", omitted)
+			omitted = 0
+		}
+		buf.Write(line)
+		if !bytes.HasSuffix(line, []byte("\n")) && i < len(lines)-1 {
+			buf.WriteByte('\n')
+		}
+	}
+	if omitted > 0 {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "... [%d lines omitted] ...\n", omitted)
+	}
+	return buf.Bytes()
+}
+
+// diffFindings returns the secrets starting in the removed lines of a
+// diff, with the line they had in the old file. Added lines are already
+// part of the content.
+func diffFindings(path string, diff []byte) []SecretFinding {
+	matches := findSecrets(path, diff)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	var findings []SecretFinding
+	offset, oldLine := 0, 0
+	for _, line := range bytes.SplitAfter(diff, []byte("\n")) {
+		start := offset
+		offset += len(line)
+		switch {
+		case bytes.HasPrefix(line, []byte("--- ")):
+		case bytes.HasPrefix(line, []byte("@@ ")):
+			if fields := strings.Fields(string(line)); len(fields) > 1 {
+				oldLine, _ = strconv.Atoi(strings.SplitN(strings.TrimPrefix(fields[1], "-"), ",", 2)[0])
+			}
+		case bytes.HasPrefix(line, []byte("-")):
+			for _, m := range matches {
+				if m.start >= start && m.start < offset {
+					findings = append(findings, SecretFinding{Path: path, Line: oldLine, Kind: m.kind})
+				}
+			}
+			oldLine++
+		case bytes.HasPrefix(line, []byte(" ")):
+			oldLine++
+		}
+	}
+	return findings
+}
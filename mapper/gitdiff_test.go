@@ -0,0 +1,137 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPatch(t *testing.T) {
+	patch := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -2,3 +2,4 @@ package a
+ one
+-two
++TWO
++three
+ four
+@@ -10,2 +11,1 @@
+ ten
+-eleven
+diff --git a/bin.dat b/bin.dat
+Binary files a/bin.dat and b/bin.dat differ
+diff --git "a/sp\303\244ce.txt" "b/sp\303\244ce.txt"
+--- "a/sp\303\244ce.txt"
++++ "b/sp\303\244ce.txt"
+@@ -1 +1 @@
+-x
++y
+`
+	files := splitPatch([]byte(patch))
+	if len(files) != 2 {
+		t.Fatalf("splitPatch returned %d files; want 2", len(files))
+	}
+	if files[0].rel != "a.go" || !reflect.DeepEqual(files[0].changed, []int{3, 4, 12}) {
+		t.Errorf("a.go: rel %q, changed %v; want [3 4 12]", files[0].rel, files[0].changed)
+	}
+	if !strings.HasPrefix(string(files[0].diff), "--- a/a.go\n") || !strings.HasSuffix(string(files[0].diff), "-eleven\n") {
+		t.Errorf("unexpected a.go diff:\n%s", files[0].diff)
+	}
+	if files[1].rel != "späce.txt" || !reflect.DeepEqual(files[1].changed, []int{1}) {
+		t.Errorf("quoted path: rel %q, changed %v", files[1].rel, files[1].changed)
+	}
+}
+
+func TestDiffContext(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line%d", i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	got := string(diffContext([]byte(content), []int{5, 6, 19}, 1))
+	want := "... [3 lines omitted] ...\nline4\nline5\nline6\nline7\n... [10 lines omitted] ...\nline18\nline19\nline20\n"
+	if got != want {
+		t.Errorf("diffContext =\n%s\nwant\n%s", got, want)
+	}
+	if got := string(diffContext([]byte("a\nb"), []int{1}, 0)); got != "a\n... [1 lines omitted] ...\n" {
+		t.Errorf("diffContext without a trailing newline = %q", got)
+	}
+}
+
+func TestRunChangedFiles(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		"a.go":         "package a\n\n// one\n// two\n// three\n// four\n// five\n// six\n",
+		"same.go":      "package a\n",
+		"sub/b.go":     "package sub\n",
+		"other/c.go":   "package other\n",
+		"config.env":   "API_TOKEN=\"Zx81!qP0r7#Lm2\"\n",
+		"gone/old.txt": "old\n",
+	})
+	runGit(t, dir, "tag", "base")
+
+	writeFiles(t, dir, map[string]string{
+		"a.go":       "package a\n\n// one\n// two\n// three\n// FOUR\n// five\n// six\n",
+		"sub/b.go":   "package sub\n\nvar B = 1\n",
+		"config.env": "API_TOKEN=\n",
+		"new.txt":    "brand new\n",
+	})
+	runGit(t, dir, "rm", "-q", "gone/old.txt")
+	runGit(t, dir, "add", "sub/b.go")
+
+	listing := func(cfg *Config) string {
+		t.Helper()
+		entries, err := Walk(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.RelPath)
+		}
+		return strings.Join(got, ",")
+	}
+
+	if got, want := listing(&Config{RootPath: dir, ChangedSince: "base"}), "a.go,config.env,new.txt,sub,sub/b.go"; got != want {
+		t.Errorf("changed since base = %s; want %s", got, want)
+	}
+	if got, want := listing(&Config{RootPath: dir, Staged: true}), "sub,sub/b.go"; got != want {
+		t.Errorf("staged = %s; want %s", got, want)
+	}
+	if got, want := listing(&Config{RootPath: dir, Unstaged: true}), "a.go,config.env,new.txt"; got != want {
+		t.Errorf("unstaged = %s; want %s", got, want)
+	}
+	if got, want := listing(&Config{RootPath: filepath.Join(dir, "sub"), ChangedSince: "base"}), "b.go"; got != want {
+		t.Errorf("changed since base in sub = %s; want %s", got, want)
+	}
+
+	// Only the changed line and one line around it are shown, then the diff
+	var secrets []SecretFinding
+	cfg := &Config{RootPath: dir, ChangedSince: "base", Include: "*.go,*.env", ShowContent: true, SeparateContent: true,
+		DiffContext: 1, ShowDiff: true, Secrets: SecretsRedact, OnSecret: func(f SecretFinding) { secrets = append(secrets, f) }}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"... [4 lines omitted] ...\n// three\n// FOUR\n// five\n... [1 lines omitted] ...\n",
+		"@@ -5,3 +5,3 @@ package a\n // three\n-// four\n+// FOUR\n // five\n",
+		"-API_TOKEN=\"[REDACTED:secret]\"\n+API_TOKEN=\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if len(secrets) != 1 || secrets[0].Line != 1 || secrets[0].Kind != "secret" {
+		t.Errorf("secrets in removed lines = %+v", secrets)
+	}
+
+	if _, err := Run(&Config{RootPath: dir, Staged: true, Unstaged: true}); err == nil {
+		t.Error("expected an error combining Staged and Unstaged")
+	}
+}
@@ -0,0 +1,365 @@
+package mapper
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignorePattern is a single parsed line of a gitignore-style file
+type ignorePattern struct {
+	base    string // absolute directory the pattern is relative to
+	pattern string // slash-separated glob, "**/" prefixed when unanchored
+	negate  bool   // line started with "!"
+	dirOnly bool   // line ended with "/"
+}
+
+// ignoreMatcher evaluates gitignore-style patterns collected from several files.
+// Patterns are kept in precedence order, so the last matching one wins.
+type ignoreMatcher struct {
+	fileName string                                   // per-directory file picked up by loadDir (e.g. ".gitignore")
+	open     func(path string) (io.ReadCloser, error) // reads per-directory files, if not from disk (for Config.Rev)
+	patterns []ignorePattern
+}
+
+// newGitignoreMatcher prepares a matcher for root. It loads core.excludesFile,
+// the repository's info/exclude and every .gitignore between the repository
+// top and root. The .gitignore files inside root are loaded while walking.
+// If open is set, .gitignore files are read through it.
+func newGitignoreMatcher(root string, open func(path string) (io.ReadCloser, error)) (*ignoreMatcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ignoreMatcher{fileName: ".gitignore", open: open}
+	top, gitDir := findGitRepo(absRoot)
+
+	base := absRoot
+	if top != "" {
+		base = top
+	}
+	if f := globalExcludesFile(gitDir); f != "" {
+		if err := m.addFile(f, base); err != nil {
+			return nil, err
+		}
+	}
+	if gitDir == "" {
+		return m, nil
+	}
+	if err := m.addFile(filepath.Join(commonGitDir(gitDir), "info", "exclude"), top); err != nil {
+		return nil, err
+	}
+
+	// Parent directories of root may carry their own .gitignore files
+	rel, err := filepath.Rel(top, absRoot)
+	if err != nil || rel == "." {
+		return m, nil
+	}
+	dir := top
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if err := m.loadDir(dir); err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(dir, part)
+	}
+	return m, nil
+}
+
+// newFileMapperIgnoreMatcher prepares a matcher for .filemapperignore files,
+// read through open if it's set. The extra files (from --ignore-file) must
+// exist on disk and are relative to root.
+func newFileMapperIgnoreMatcher(root string, extraFiles []string, open func(path string) (io.ReadCloser, error)) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{fileName: ".filemapperignore", open: open}
+	for _, f := range extraFiles {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(root, f)
+		}
+		if _, err := os.Stat(f); err != nil {
+			return nil, err
+		}
+		if err := m.addFile(f, root); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// loadIgnoreDir loads the per-directory ignore file of every matcher from dir
+func loadIgnoreDir(matchers []*ignoreMatcher, dir string) error {
+	for _, m := range matchers {
+		if err := m.loadDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isIgnoredByAny reports whether any of the matchers ignores the path.
+// Each matcher is evaluated on its own, so a "!" in one cannot re-include
+// a path that another one ignores.
+func isIgnoredByAny(matchers []*ignoreMatcher, path string, isDir bool) bool {
+	for _, m := range matchers {
+		if m.isIgnored(path, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDir reads the matcher's per-directory file from dir, if there is one
+func (m *ignoreMatcher) loadDir(dir string) error {
+	path := filepath.Join(dir, m.fileName)
+	if m.open == nil {
+		return m.addFile(path, dir)
+	}
+	r, err := m.open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+	return m.addPatterns(r, dir)
+}
+
+// addFile parses a gitignore-style file whose patterns are relative to base.
+// A missing file is not an error.
+func (m *ignoreMatcher) addFile(path, base string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return m.addPatterns(f, base)
+}
+
+// addPatterns parses gitignore-style lines whose patterns are relative to base
+func (m *ignoreMatcher) addPatterns(r io.Reader, base string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := parseIgnorePattern(scanner.Text(), base); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return scanner.Err()
+}
+
+// isIgnored reports whether the absolute path is ignored by the loaded patterns
+func (m *ignoreMatcher) isIgnored(path string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(p.base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if matchGlob(p.pattern, filepath.ToSlash(rel)) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnorePattern turns one line of a gitignore-style file into a pattern.
+// It returns false for blank lines and comments.
+func parseIgnorePattern(line, base string) (ignorePattern, bool) {
+	line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	p := ignorePattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to base;
+	// otherwise it matches at any depth.
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	p.pattern = line
+	return p, true
+}
+
+// trimTrailingSpaces drops trailing spaces unless they are escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// findGitRepo walks up from dir looking for a ".git" directory or file.
+// It returns the working tree top and the git directory, or empty strings.
+func findGitRepo(dir string) (top, gitDir string) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		if err == nil {
+			if info.IsDir() {
+				return dir, dotGit
+			}
+			// Linked worktrees and submodules use a "gitdir: <path>" file
+			if target := readGitDirFile(dotGit); target != "" {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dir, target)
+				}
+				return dir, filepath.Clean(target)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// nestedRepoKind tells what kind of repository has its working tree top at
+// dir, if any: submodules and linked worktrees have a "gitdir: <path>" file
+// pointing into the modules or worktrees directory of another repository
+func nestedRepoKind(dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err != nil:
+		return ""
+	case info.IsDir():
+		return NestedRepo
+	}
+	target := filepath.ToSlash(readGitDirFile(dotGit))
+	switch {
+	case target == "":
+		return ""
+	case strings.Contains(target, "/modules/"):
+		return NestedSubmodule
+	case strings.Contains(target, "/worktrees/"):
+		return NestedWorktree
+	}
+	return NestedRepo
+}
+
+// readGitDirFile returns the target of a "gitdir: <path>" file
+func readGitDirFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+}
+
+// commonGitDir resolves the directory shared by all worktrees of a repository
+func commonGitDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
+}
+
+// globalExcludesFile locates core.excludesFile by reading the git config files
+// directly, falling back to Git's default of $XDG_CONFIG_HOME/git/ignore.
+func globalExcludesFile(gitDir string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	// Later files take precedence, mirroring git's own lookup order
+	var configs []string
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	if gitDir != "" {
+		configs = append(configs, filepath.Join(commonGitDir(gitDir), "config"))
+	}
+
+	file := ""
+	for _, c := range configs {
+		if v := readGitConfigValue(c, "core", "excludesfile"); v != "" {
+			file = v
+		}
+	}
+	if file == "" {
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if strings.HasPrefix(file, "~/") && home != "" {
+		file = filepath.Join(home, file[2:])
+	}
+	return file
+}
+
+// readGitConfigValue does a minimal parse of a git config file and returns
+// the last value of section.key. Section and key names are case-insensitive.
+func readGitConfigValue(path, section, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	value := ""
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name := strings.Trim(line, "[]")
+			inSection = strings.EqualFold(strings.TrimSpace(name), section)
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		k, v, found := strings.Cut(line, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(k), key) {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	return value
+}
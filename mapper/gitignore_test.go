@@ -0,0 +1,209 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnorePattern(t *testing.T) {
+	cases := []struct {
+		line    string
+		ok      bool
+		pattern string
+		negate  bool
+		dirOnly bool
+	}{
+		{"", false, "", false, false},
+		{"# comment", false, "", false, false},
+		{"*.log", true, "**/*.log", false, false},
+		{"/build", true, "build", false, false},
+		{"build/", true, "**/build", false, true},
+		{"docs/gen/", true, "docs/gen", false, true},
+		{"!keep.log", true, "**/keep.log", true, false},
+		{`\!bang`, true, "**/!bang", false, false},
+		{`\#hash`, true, "**/#hash", false, false},
+		{"trailing.txt   ", true, "**/trailing.txt", false, false},
+	}
+	for _, c := range cases {
+		p, ok := parseIgnorePattern(c.line, "/base")
+		if ok != c.ok {
+			t.Errorf("parseIgnorePattern(%q) ok = %v; want %v", c.line, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if p.pattern != c.pattern || p.negate != c.negate || p.dirOnly != c.dirOnly {
+			t.Errorf("parseIgnorePattern(%q) = %+v; want pattern=%q negate=%v dirOnly=%v",
+				c.line, p, c.pattern, c.negate, c.dirOnly)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	base := filepath.FromSlash("/repo")
+	m := &ignoreMatcher{}
+	for _, line := range []string{"*.log", "!keep.log", "/build", "out/", "docs/**/*.tmp"} {
+		p, _ := parseIgnorePattern(line, base)
+		m.patterns = append(m.patterns, p)
+	}
+
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"sub/debug.log", false, true},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"sub/build", true, false},
+		{"out", true, true},
+		{"out", false, false},
+		{"sub/out", true, true},
+		{"docs/a/b/x.tmp", false, true},
+		{"src/x.tmp", false, false},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		path := filepath.Join(base, filepath.FromSlash(c.path))
+		if got := m.isIgnored(path, c.isDir); got != c.want {
+			t.Errorf("isIgnored(%q, dir=%v) = %v; want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestRunWithGitignore(t *testing.T) {
+	// Keep the user's own global excludes out of the test
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tmp := t.TempDir()
+	mustWrite := func(rel, content string) {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustWrite(".git/info/exclude", "secret.txt\n")
+	mustWrite(".gitignore", "build/\n*.log\n")
+	mustWrite("sub/.gitignore", "!important.log\n/local.txt\n")
+	mustWrite("main.go", "package main")
+	mustWrite("new_untracked.go", "package main")
+	mustWrite("build/app.txt", "artifact")
+	mustWrite("debug.log", "log")
+	mustWrite("secret.txt", "shh")
+	mustWrite("sub/important.log", "keep me")
+	mustWrite("sub/other.log", "drop me")
+	mustWrite("sub/local.txt", "drop me")
+	mustWrite("sub/deeper/local.txt", "keep me")
+
+	cfg := &Config{
+		RootPath:     tmp,
+		UseGitignore: true,
+		ShowTree:     false,
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	for _, want := range []string{"main.go", "new_untracked.go", "important.log", filepath.Join("deeper", "local.txt")} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"app.txt", "debug.log", "secret.txt", "other.log", filepath.Join("sub", "local.txt")} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Expected %q to be ignored:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestGlobalExcludesFile(t *testing.T) {
+	home := t.TempDir()
+	xdg := filepath.Join(home, "xdg")
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	// Default location when nothing is configured
+	if got := globalExcludesFile(""); got != filepath.Join(xdg, "git", "ignore") {
+		t.Errorf("Expected default excludes file, got %q", got)
+	}
+
+	cfg := "[user]\n\tname = x\n[core]\n\texcludesFile = ~/my-ignore\n"
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := globalExcludesFile(""); got != filepath.Join(home, "my-ignore") {
+		t.Errorf("Expected configured excludes file, got %q", got)
+	}
+}
+
+func TestRunWithFileMapperIgnore(t *testing.T) {
+	tmp := t.TempDir()
+	mustWrite := func(rel, content string) {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustWrite(".filemapperignore", "fixtures/\n*.lock\n")
+	mustWrite("pkg/.filemapperignore", "*.snap\n")
+	mustWrite("main.go", "package main")
+	mustWrite("go.lock", "lock")
+	mustWrite("fixtures/big.json", "{}")
+	mustWrite("pkg/code.go", "package pkg")
+	mustWrite("pkg/view.snap", "snapshot")
+	mustWrite("notes.md", "notes")
+
+	extra := filepath.Join(t.TempDir(), "extra-ignore")
+	if err := os.WriteFile(extra, []byte("/notes.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		RootPath:    tmp,
+		IgnoreFiles: extra,
+		Exclude:     "main.go",
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	if !strings.Contains(out, "code.go") {
+		t.Errorf("Expected code.go in output:\n%s", out)
+	}
+	for _, unwanted := range []string{"main.go", "go.lock", "big.json", "view.snap", "notes.md"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Expected %q to be ignored:\n%s", unwanted, out)
+		}
+	}
+
+	// A relative --ignore-file is found in the root, not the current directory
+	mustWrite("my.ignore", "*.md\n")
+	cfg.IgnoreFiles = "my.ignore"
+	if out, err = Run(cfg); err != nil {
+		t.Fatalf("Run with a relative ignore file: %v", err)
+	}
+	if strings.Contains(out, "notes.md") || !strings.Contains(out, "code.go") {
+		t.Errorf("Expected notes.md to be ignored by my.ignore:\n%s", out)
+	}
+
+	// A missing --ignore-file is reported instead of silently ignored
+	cfg.IgnoreFiles = filepath.Join(tmp, "does-not-exist")
+	if _, err := Run(cfg); err == nil {
+		t.Error("Expected an error for a missing ignore file")
+	}
+}
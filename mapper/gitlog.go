@@ -0,0 +1,129 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is the last commit that changed a file, for Config.GitLog
+type Commit struct {
+	Hash    string // abbreviated
+	Author  string
+	Date    time.Time // commit date
+	Subject string
+}
+
+// commitSummary returns what listings and content headers show for a
+// file's last commit, e.g. "a1b2c3d, Jane Doe, 3 days ago: Fix the parser",
+// or "" if it has none
+func commitSummary(c *Commit) string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s, %s, %s: %s", c.Hash, c.Author, relativeTime(c.Date, time.Now()), c.Subject)
+}
+
+// annotateLastCommits sets the Entry.LastCommit of every file committed at
+// or before cfg.Rev (HEAD by default). A single git log pass walks the
+// history from the newest commit and stops once every file has been seen.
+func annotateLastCommits(ctx context.Context, cfg *Config, entries []Entry) error {
+	wanted := make(map[string]int)
+	for i, e := range entries {
+		if !e.IsDir {
+			wanted[e.RelPath] = i
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	rev := cfg.Rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	// Killing git once it isn't needed any more is expected
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotePath=false", "log", "--name-only", "--relative", "-z",
+		"--format=%x01%h%x00%an%x00%ct%x00%s", rev, "--", ".")
+	cmd.Dir = cfg.RootPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// "\x01<hash>\0<author>\0<time>\0<subject>\0\n<path>\0<path>\0..." per commit
+	r := bufio.NewReader(stdout)
+	var commit *Commit
+	for len(wanted) > 0 {
+		field, err := r.ReadString(0)
+		if err != nil {
+			break
+		}
+		field = strings.TrimPrefix(strings.TrimSuffix(field, "\x00"), "\n")
+		if strings.HasPrefix(field, "\x01") {
+			commit = &Commit{Hash: field[1:]}
+			var parts [3]string
+			for i := range parts {
+				if parts[i], err = r.ReadString(0); err != nil {
+					break
+				}
+				parts[i] = strings.TrimSuffix(parts[i], "\x00")
+			}
+			secs, _ := strconv.ParseInt(parts[1], 10, 64)
+			commit.Author, commit.Date, commit.Subject = parts[0], time.Unix(secs, 0), parts[2]
+			continue
+		}
+		if i, ok := wanted[field]; ok && commit != nil {
+			entries[i].LastCommit = commit
+			delete(wanted, field)
+		}
+	}
+
+	if len(wanted) == 0 {
+		cancel()
+		cmd.Wait()
+		return nil
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to read git log: %s", msg)
+		}
+		return fmt.Errorf("failed to read git log: %v", err)
+	}
+	return nil
+}
+
+// relativeTime describes t the way git log --date=relative does, e.g.
+// "3 days ago"
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	days := int(d.Hours() / 24)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute") + " ago"
+	case d < 24*time.Hour:
+		return plural(int(d.Hours()), "hour") + " ago"
+	case days < 14:
+		return plural(days, "day") + " ago"
+	case days < 60:
+		return plural(days/7, "week") + " ago"
+	case days < 365:
+		return plural(days/30, "month") + " ago"
+	}
+	return plural(days/365, "year") + " ago"
+}
@@ -0,0 +1,66 @@
+package mapper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{5 * time.Hour, "5 hours ago"},
+		{3 * 24 * time.Hour, "3 days ago"},
+		{20 * 24 * time.Hour, "2 weeks ago"},
+		{100 * 24 * time.Hour, "3 months ago"},
+		{800 * 24 * time.Hour, "2 years ago"},
+	}
+	for _, tt := range tests {
+		if got := relativeTime(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("relativeTime(%v ago) = %q; want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestRunGitLog(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{"a.go": "package a\n", "sub/b.go": "package sub\n"})
+	writeFiles(t, dir, map[string]string{"sub/b.go": "package sub\n\nvar B = 1\n"})
+	runGit(t, dir, "commit", "-q", "-am", "Update b")
+	hash := strings.TrimSpace(runGit(t, dir, "log", "-1", "--format=%h"))
+	writeFiles(t, dir, map[string]string{"new.go": "package a\n"})
+
+	cfg := &Config{RootPath: dir, GitLog: true, ShowTree: true, ShowContent: true, SeparateContent: true}
+	entries, err := Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjects := make(map[string]string)
+	for _, e := range entries {
+		if e.LastCommit != nil {
+			subjects[e.RelPath] = e.LastCommit.Subject
+		}
+	}
+	if subjects["a.go"] != "initial" || subjects["sub/b.go"] != "Update b" || len(subjects) != 2 {
+		t.Errorf("last commit subjects = %v", subjects)
+	}
+
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"│   └── b.go [" + hash + ", Test, just now: Update b]\n",
+		"├── new.go\n",
+		"b.go (4 lines) [" + hash + ", Test, just now: Update b]:\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
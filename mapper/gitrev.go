@@ -0,0 +1,406 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitTree is the tree of a Git revision, read for Config.Rev. Walk lists it
+// instead of the working tree, and file content comes from its blobs.
+type gitTree struct {
+	root    string
+	rev     string
+	modTime time.Time            // the commit time, used for every entry
+	files   map[string]*gitFile  // by path joined with root, like Entry.Path
+	batches map[string]*catBatch // by repository, while reading many files; see batch
+}
+
+// gitFile is a blob or tree in a gitTree
+type gitFile struct {
+	rel       string // slash-separated, relative to the root
+	object    string
+	mode      fs.FileMode
+	size      int64
+	repo      string // the submodule holding object, if not the root's repository
+	submodule bool   // a submodule's root directory
+}
+
+// loadGitTree lists the tree of rev below root with git ls-tree. Content
+// is only read when a file is opened. Submodules are listed as empty directories,
+// unless recurse is set and they're checked out; their own tree at the
+// recorded commit is then listed inside them.
+func loadGitTree(ctx context.Context, root, rev string, recurse bool) (*gitTree, error) {
+	out, err := gitOutput(ctx, root, "log", "-1", "--format=%ct", rev, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %q: %v", rev, err)
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %q: unexpected commit time %q", rev, out)
+	}
+
+	// "<rev>:./" is the tree of root's directory, with paths relative to it;
+	// --full-tree stops git from also filtering them by root's path
+	out, err = gitOutput(ctx, root, "ls-tree", "--full-tree", "-r", "-t", "-l", "-z", rev+":./")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision %q: %v", rev, err)
+	}
+
+	t := &gitTree{root: root, rev: rev, modTime: time.Unix(secs, 0), files: make(map[string]*gitFile)}
+	for _, record := range bytes.Split(out, []byte{0}) {
+		// "<mode> SP <type> SP <object> SP+ <size> TAB <path>"
+		tab := bytes.IndexByte(record, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(string(record[:tab]))
+		if len(fields) != 4 {
+			continue
+		}
+		f := &gitFile{rel: string(record[tab+1:]), object: fields[2]}
+		switch fields[1] {
+		case "tree":
+			f.mode = fs.ModeDir | 0755
+		case "commit":
+			f.mode = fs.ModeDir | 0755
+			f.submodule = true
+		case "blob":
+			f.mode = 0644
+			if fields[0] == "100755" {
+				f.mode = 0755
+			} else if fields[0] == "120000" {
+				f.mode = fs.ModeSymlink | 0777
+			}
+			f.size, _ = strconv.ParseInt(fields[3], 10, 64)
+		default:
+			continue
+		}
+		t.files[t.path(f.rel)] = f
+
+		if f.submodule && recurse && nestedRepoKind(t.path(f.rel)) != "" {
+			sub, err := loadGitTree(ctx, t.path(f.rel), f.object, true)
+			if err != nil {
+				return nil, err
+			}
+			for p, sf := range sub.files {
+				sf.rel = f.rel + "/" + sf.rel
+				if sf.repo == "" {
+					sf.repo = sub.root
+				}
+				t.files[p] = sf
+			}
+		}
+	}
+	return t, nil
+}
+
+// gitOutput runs git in dir and returns its output, or stderr as the error
+func gitOutput(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// path turns a relative path into the one Walk reports
+func (t *gitTree) path(rel string) string {
+	return filepath.Join(t.root, filepath.FromSlash(rel))
+}
+
+// walk calls fn for the root and everything in the tree like filepath.Walk
+// does for a directory: lexically, parents before their children, and
+// skipping a directory's contents when fn returns filepath.SkipDir
+func (t *gitTree) walk(fn filepath.WalkFunc) error {
+	children := make(map[string][]*gitFile)
+	for _, f := range t.files {
+		dir := path.Dir(f.rel)
+		children[dir] = append(children[dir], f)
+	}
+
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		kids := children[dir]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].rel < kids[j].rel })
+		for _, f := range kids {
+			err := fn(t.path(f.rel), t.info(f), nil)
+			if err == filepath.SkipDir && f.mode.IsDir() {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if f.mode.IsDir() {
+				if err := visit(f.rel); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	root := gitFileInfo{name: filepath.Base(t.root), mode: fs.ModeDir | 0755, modTime: t.modTime}
+	if err := fn(t.root, root, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return visit(".")
+}
+
+// stat returns the info of a file or directory in the tree
+func (t *gitTree) stat(p string) (fs.FileInfo, error) {
+	if p == t.root {
+		return gitFileInfo{name: filepath.Base(t.root), mode: fs.ModeDir | 0755, modTime: t.modTime}, nil
+	}
+	f := t.files[p]
+	if f == nil {
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return t.info(f), nil
+}
+
+// open streams the content of a file from the repository holding it,
+// through its git cat-file --batch if one is running and not busy, or else
+// a git cat-file of its own
+func (t *gitTree) open(p string) (io.ReadCloser, error) {
+	f := t.files[p]
+	if f == nil || f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	if f.size == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	repo := f.repo
+	if repo == "" {
+		repo = t.root
+	}
+	if b := t.batches[repo]; b != nil && b.current == nil {
+		return b.open(f)
+	}
+	return catBlob(repo, f.object)
+}
+
+// openIgnoreFile opens a per-directory ignore file, given its absolute
+// path, as of the revision. Those above the root come from the revision
+// too, like the tree below it.
+func (t *gitTree) openIgnoreFile(p string) (io.ReadCloser, error) {
+	root, err := filepath.Abs(t.root)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return t.open(t.path(rel))
+	}
+	// "<rev>:../<path>" is relative to the directory git runs in
+	data, err := gitOutput(context.Background(), t.root, "cat-file", "blob", t.rev+":"+filepath.ToSlash(rel))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// batch starts reading files through a git cat-file --batch per repository,
+// rather than a git process per file, until the returned stop is called.
+// The processes only start once a file is opened.
+func (t *gitTree) batch() (stop func()) {
+	if t == nil || t.batches != nil {
+		return func() {} // already reading in a batch
+	}
+	t.batches = make(map[string]*catBatch)
+	for _, f := range t.files {
+		repo := f.repo
+		if repo == "" {
+			repo = t.root
+		}
+		if t.batches[repo] == nil {
+			t.batches[repo] = &catBatch{dir: repo}
+		}
+	}
+	return func() {
+		for _, b := range t.batches {
+			b.stop()
+		}
+		t.batches = nil
+	}
+}
+
+// catBlob streams a single object with a git cat-file of its own
+func catBlob(dir, object string) (io.ReadCloser, error) {
+	cmd := exec.Command("git", "cat-file", "blob", object)
+	cmd.Dir = dir
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// cmdReader is the output of a command, which is waited for on Close
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *cmdReader) Close() error {
+	// Closing early stops git with a broken pipe, which isn't an error here
+	r.ReadCloser.Close()
+	r.cmd.Wait()
+	return nil
+}
+
+// catBatch is a git cat-file --batch reading the objects of one repository
+// one at a time
+type catBatch struct {
+	dir     string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	current *blobReader // the object being read, if any
+}
+
+// maxDrain is how much of an object closed early is read to the end, to
+// keep the process going; with more left, it's restarted instead
+const maxDrain = 64 << 10
+
+// open starts reading the object of f
+func (b *catBatch) open(f *gitFile) (io.ReadCloser, error) {
+	if b.cmd == nil {
+		cmd := exec.Command("git", "cat-file", "--batch")
+		cmd.Dir = b.dir
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		b.cmd, b.stdin, b.stdout = cmd, stdin, bufio.NewReader(stdout)
+	}
+
+	// "<object> <type> <size>\n<content>\n"
+	if _, err := io.WriteString(b.stdin, f.object+"\n"); err != nil {
+		b.stop()
+		return nil, fmt.Errorf("failed to read %s: %v", f.rel, err)
+	}
+	header, err := b.stdout.ReadString('\n')
+	if err != nil {
+		b.stop()
+		return nil, fmt.Errorf("failed to read %s: %v", f.rel, err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("failed to read %s: %s", f.rel, strings.TrimSpace(header))
+	}
+	size, _ := strconv.ParseInt(fields[2], 10, 64)
+	b.current = &blobReader{b: b, left: size}
+	return b.current, nil
+}
+
+// stop ends the process. An object still being read is cut short.
+func (b *catBatch) stop() {
+	if b.cmd == nil {
+		return
+	}
+	if b.current != nil {
+		b.current.b = nil
+		b.current = nil
+	}
+	b.stdin.Close()
+	if b.cmd.Process != nil {
+		b.cmd.Process.Kill()
+	}
+	b.cmd.Wait()
+	b.cmd, b.stdin, b.stdout = nil, nil, nil
+}
+
+// blobReader reads one object from a catBatch
+type blobReader struct {
+	b    *catBatch // nil once closed
+	left int64
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.b == nil {
+		return 0, fmt.Errorf("read of a closed object")
+	}
+	if r.left == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.b.stdout.Read(p)
+	r.left -= int64(n)
+	if err == io.EOF && r.left > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Close skips what's left of the object and the newline after it
+func (r *blobReader) Close() error {
+	b := r.b
+	if b == nil {
+		return nil
+	}
+	r.b = nil
+	b.current = nil
+	if r.left > maxDrain {
+		b.stop()
+		return nil
+	}
+	if _, err := b.stdout.Discard(int(r.left) + 1); err != nil {
+		b.stop()
+	}
+	return nil
+}
+
+func (t *gitTree) info(f *gitFile) fs.FileInfo {
+	return gitFileInfo{name: path.Base(f.rel), size: f.size, mode: f.mode, modTime: t.modTime}
+}
+
+// gitFileInfo implements fs.FileInfo for entries of a gitTree
+type gitFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i gitFileInfo) Name() string       { return i.name }
+func (i gitFileInfo) Size() int64        { return i.size }
+func (i gitFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i gitFileInfo) ModTime() time.Time { return i.modTime }
+func (i gitFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i gitFileInfo) Sys() interface{}   { return nil }
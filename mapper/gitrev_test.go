@@ -0,0 +1,232 @@
+package mapper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runGit runs git in dir, failing the test if it fails
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// writeFiles creates files (by slash-separated path) below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newGitRepo creates a repository holding files in one commit
+func newGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	writeFiles(t, dir, files)
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func TestRunRev(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"pkg/util.go":    "package pkg\n\n// Version is the release\nvar Version = \"1.0\"\n",
+		"pkg/data.bin":   "\x00\x01\x02",
+		"docs/readme.md": "# Old docs\n",
+	})
+	runGit(t, dir, "tag", "v1")
+
+	// Change the working tree after the tag
+	writeFiles(t, dir, map[string]string{"pkg/util.go": "package pkg\n\nvar Version = \"2.0\"\n", "new.go": "package main\n"})
+	os.RemoveAll(filepath.Join(dir, "docs"))
+
+	cfg := &Config{RootPath: dir, Rev: "v1", ShowTree: true, ShowContent: true, SeparateContent: true, ShowHeaderFooters: true}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"├── docs\n", "readme.md", "# Old docs", `var Version = "1.0"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output for v1 is missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"new.go", `"2.0"`, "data.bin"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output for v1 shouldn't contain %q:\n%s", unwanted, out)
+		}
+	}
+
+	// From a subdirectory, only its part of the revision is listed
+	cfg = &Config{RootPath: filepath.Join(dir, "pkg"), Rev: "v1", Format: FormatJSON, ShowContent: true}
+	entries, err := Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.RelPath)
+	}
+	if want := "data.bin,util.go"; strings.Join(got, ",") != want {
+		t.Errorf("Walk of pkg at v1 = %v; want %s", got, want)
+	}
+	if !entries[0].Binary {
+		t.Error("data.bin should be flagged as binary")
+	}
+	if entries[1].Info.Size() != int64(len("package pkg\n\n// Version is the release\nvar Version = \"1.0\"\n")) {
+		t.Errorf("util.go size = %d", entries[1].Info.Size())
+	}
+
+	// The root node is the revision's too, not the working tree's
+	old := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "pkg"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	out, err = Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Tree struct {
+			ModTime  time.Time `json:"mtime"`
+			Children []struct {
+				ModTime time.Time `json:"mtime"`
+			} `json:"children"`
+		} `json:"tree"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(doc.Tree.Children) == 0 || !doc.Tree.ModTime.Equal(doc.Tree.Children[0].ModTime) {
+		t.Errorf("root mtime %v doesn't match the revision's:\n%s", doc.Tree.ModTime, out)
+	}
+
+	// Custom renderers read the revision's content, transformed and
+	// redacted like the built-in ones
+	cfg = &Config{RootPath: filepath.Join(dir, "pkg"), Rev: "v1", StripComments: true, Secrets: SecretsRedact}
+	entries, err = Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenContent(cfg, entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != "package pkg\n\nvar Version = \"1.0\"\n" {
+		t.Errorf("OpenContent(util.go) = %q, %v", content, err)
+	}
+
+	if _, err := Run(&Config{RootPath: dir, Rev: "no-such-rev"}); err == nil || !strings.Contains(err.Error(), "no-such-rev") {
+		t.Errorf("expected an error naming the unknown revision, got %v", err)
+	}
+}
+
+// TestRunRevIgnoreFiles checks that ignore files are read from the revision,
+// including a .gitignore above the root
+func TestRunRevIgnoreFiles(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		".gitignore":            "*.gen.go\n",
+		"src/.filemapperignore": "fixtures/\n",
+		"src/main.go":           "package main\n",
+		"src/api.gen.go":        "package main\n",
+		"src/fixtures/a.json":   "{}\n",
+	})
+	runGit(t, dir, "tag", "v1")
+	writeFiles(t, dir, map[string]string{".gitignore": "main.go\n", "src/.filemapperignore": "\n"})
+
+	entries, err := Walk(context.Background(), &Config{RootPath: filepath.Join(dir, "src"), Rev: "v1", UseGitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.RelPath)
+	}
+	if want := "main.go"; strings.Join(got, ",") != want {
+		t.Errorf("Walk at v1 = %v; want %s", got, want)
+	}
+}
+
+// TestGitTreeOpen checks that blobs are streamed, both through a batch and
+// with a process of their own
+func TestGitTreeOpen(t *testing.T) {
+	big := strings.Repeat("0123456789abcdef", 10000) // over maxDrain
+	dir := newGitRepo(t, map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n", "big.txt": big, "empty.txt": ""})
+	tree, err := loadGitTree(context.Background(), dir, "HEAD", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string, n int) string {
+		r, err := tree.open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		buf := make([]byte, n)
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return string(buf[:n])
+	}
+
+	// Without a batch
+	if got := read("a.txt", 100); got != "alpha\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+
+	stop := tree.batch()
+	defer stop()
+	// Objects closed early are skipped, or the process restarted for big ones
+	for _, name := range []string{"big.txt", "a.txt", "big.txt", "b.txt", "empty.txt", "big.txt"} {
+		if got := read(name, 4); got != map[string]string{"a.txt": "alph", "b.txt": "beta", "big.txt": "0123", "empty.txt": ""}[name] {
+			t.Errorf("%s starts with %q", name, got)
+		}
+	}
+	if got := read("big.txt", len(big)+1); got != big {
+		t.Errorf("big.txt read %d bytes; want %d", len(got), len(big))
+	}
+
+	// A second file opened while the first is read gets its own process
+	ra, err := tree.open(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, err := tree.open(filepath.Join(dir, "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rb)
+	a, _ := io.ReadAll(ra)
+	ra.Close()
+	rb.Close()
+	if string(a) != "alpha\n" || string(b) != "beta\n" {
+		t.Errorf("read %q and %q at once", a, b)
+	}
+}
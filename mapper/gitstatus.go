@@ -0,0 +1,116 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Git statuses set in Entry.GitStatus for Config.GitStatus
+const (
+	GitModified   = "M"
+	GitAdded      = "A"
+	GitRenamed    = "R"
+	GitConflicted = "U"
+	GitUntracked  = "??"
+	GitIgnored    = "!!"
+	GitDirChanged = "*" // a directory holding changes (ignored files don't count)
+)
+
+// gitStatus returns the status of every changed or ignored path below root,
+// by slash-separated path relative to root, from git status. Directories
+// matched by an ignore pattern are reported once, with a trailing slash.
+// Deleted paths aren't walked, so they get no status of their own, but the
+// directories holding them get GitDirChanged like those holding any change.
+func gitStatus(ctx context.Context, root string) (map[string]string, error) {
+	// git status paths are relative to the top of the work tree
+	out, err := gitOutput(ctx, root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %v", err)
+	}
+	prefix := strings.TrimSpace(string(out))
+
+	out, err = gitOutput(ctx, root, "status", "--porcelain=v1", "-z", "--untracked-files=all", "--ignored=matching", "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %v", err)
+	}
+
+	statuses := make(map[string]string)
+	records := strings.Split(string(out), "\x00")
+	for i := 0; i < len(records); i++ {
+		// "XY <path>", followed by the original path for renames and copies
+		record := records[i]
+		if len(record) < 4 {
+			continue
+		}
+		xy, p := record[:2], record[3:]
+		if xy[0] == 'R' || xy[0] == 'C' {
+			i++
+		}
+		if !strings.HasPrefix(p, prefix) {
+			continue // outside root
+		}
+		rel := p[len(prefix):]
+
+		// A file deleted from the index but kept on disk is also untracked,
+		// and listed as such
+		status := statusLetter(xy)
+		if _, ok := statuses[rel]; !ok && status != "" {
+			statuses[rel] = status
+		}
+		if status == GitIgnored {
+			continue
+		}
+		for dir := path.Dir(strings.TrimSuffix(rel, "/")); dir != "."; dir = path.Dir(dir) {
+			statuses[dir] = GitDirChanged
+		}
+	}
+	return statuses, nil
+}
+
+// statusLetter sums up a porcelain "XY" status code (index and work tree).
+// Deletions give "".
+func statusLetter(xy string) string {
+	switch {
+	case xy == "??":
+		return GitUntracked
+	case xy == "!!":
+		return GitIgnored
+	case xy[0] == 'U' || xy[1] == 'U' || xy == "AA" || xy == "DD":
+		return GitConflicted
+	case xy[0] == 'A':
+		return GitAdded
+	case xy[0] == 'D' || xy[1] == 'D':
+		return ""
+	case xy[0] == 'R' || xy[0] == 'C':
+		return GitRenamed
+	}
+	return GitModified
+}
+
+// annotateGitStatus sets the Entry.GitStatus of the changed and ignored
+// entries. Files inside an ignored directory are ignored too.
+func annotateGitStatus(ctx context.Context, cfg *Config, entries []Entry) error {
+	if cfg.Rev != "" {
+		return fmt.Errorf("git status can't be shown for a revision")
+	}
+	statuses, err := gitStatus(ctx, cfg.RootPath)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		if status, ok := statuses[e.RelPath]; ok {
+			entries[i].GitStatus = status
+			continue
+		}
+		for dir := e.RelPath; dir != "."; dir = path.Dir(dir) {
+			if statuses[dir+"/"] == GitIgnored {
+				entries[i].GitStatus = GitIgnored
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusLetter(t *testing.T) {
+	tests := map[string]string{
+		" M": GitModified, "MM": GitModified, " T": GitModified,
+		"A ": GitAdded, "AM": GitAdded,
+		"D ": "", " D": "",
+		"R ": GitRenamed, "RM": GitRenamed,
+		"UU": GitConflicted, "AA": GitConflicted, "DU": GitConflicted,
+		"??": GitUntracked, "!!": GitIgnored,
+	}
+	for xy, want := range tests {
+		if got := statusLetter(xy); got != want {
+			t.Errorf("statusLetter(%q) = %q; want %q", xy, got, want)
+		}
+	}
+}
+
+func TestRunGitStatus(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		".gitignore":    "build/\n*.log\n",
+		"main.go":       "package main\n",
+		"same.go":       "package main\n",
+		"pkg/a.go":      "package pkg\n",
+		"pkg/old.go":    "package pkg\n\n// Old is renamed\n",
+		"pkg/keep.go":   "package pkg\n\n// Keep stays on disk\n",
+		"docs/guide.md": "# Guide\n",
+		"gone/x.txt":    "x\n",
+		"lib/old.go":    "package lib\n",
+		"lib/lib.go":    "package lib\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"main.go":       "package main\n\nfunc main() {}\n",
+		"pkg/new.go":    "package pkg\n",
+		"pkg/staged.go": "package pkg\n\n// Staged is new\n",
+		"build/out/bin": "binary-ish\n",
+		"debug.log":     "log\n",
+	})
+	runGit(t, dir, "add", "pkg/staged.go")
+	runGit(t, dir, "mv", "pkg/old.go", "pkg/renamed.go")
+	runGit(t, dir, "rm", "-q", "--cached", "pkg/keep.go")
+	os.RemoveAll(filepath.Join(dir, "gone"))
+	os.Remove(filepath.Join(dir, "lib", "old.go"))
+
+	cfg := &Config{RootPath: dir, GitStatus: true}
+	entries, err := Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, e := range entries {
+		got[e.RelPath] = e.GitStatus
+	}
+	want := map[string]string{
+		"main.go":        GitModified,
+		"same.go":        "",
+		"pkg":            GitDirChanged,
+		"pkg/a.go":       "",
+		"pkg/new.go":     GitUntracked,
+		"pkg/staged.go":  GitAdded,
+		"pkg/renamed.go": GitRenamed,
+		"pkg/keep.go":    GitUntracked,
+		"docs":           "",
+		"lib":            GitDirChanged,
+		"lib/lib.go":     "",
+		"build":          GitIgnored,
+		"build/out":      GitIgnored,
+		"build/out/bin":  GitIgnored,
+		"debug.log":      GitIgnored,
+	}
+	for rel, status := range want {
+		if got[rel] != status {
+			t.Errorf("%s: GitStatus = %q; want %q", rel, got[rel], status)
+		}
+	}
+
+	cfg.ShowTree = true
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"├── main.go [M]\n", "├── pkg [*]\n", "new.go [??]\n", "debug.log [!!]\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("tree is missing %q:\n%s", line, out)
+		}
+	}
+
+	// Paths are relative to a subdirectory root too
+	entries, err = Walk(context.Background(), &Config{RootPath: filepath.Join(dir, "pkg"), GitStatus: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.RelPath == "staged.go" && e.GitStatus != GitAdded || e.RelPath == "a.go" && e.GitStatus != "" {
+			t.Errorf("in pkg, %s: GitStatus = %q", e.RelPath, e.GitStatus)
+		}
+	}
+}
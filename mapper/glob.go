@@ -0,0 +1,42 @@
+package mapper
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash-separated name matches pattern.
+// Each path segment is matched with path.Match, and a segment consisting
+// solely of "**" matches zero or more whole segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments is the recursive helper behind matchGlob
+func matchSegments(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+
+	if patterns[0] == "**" {
+		// A trailing "**" matches everything inside, but not the parent itself
+		if len(patterns) == 1 {
+			return len(names) > 0
+		}
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(patterns[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+	matched, err := path.Match(patterns[0], names[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchSegments(patterns[1:], names[1:])
+}
@@ -0,0 +1,29 @@
+package mapper
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "dir/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/main.go", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/c", false},
+		{"build/**", "build/out/app", true},
+		{"build/**", "build", false},
+		{"docs/*.md", "docs/readme.md", true},
+		{"docs/*.md", "docs/sub/readme.md", false},
+		{"[ab].txt", "a.txt", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v; want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonDocument is the top-level object emitted by --format=json
+type jsonDocument struct {
+	Root string    `json:"root"`
+	Tree *jsonNode `json:"tree"`
+}
+
+// jsonNode describes a single directory or file in the JSON tree
+type jsonNode struct {
+	Name          string      `json:"name"`
+	Path          string      `json:"path"` // relative to the root, slash-separated
+	Type          string      `json:"type"` // "dir" or "file"
+	Size          int64       `json:"size"`
+	Mode          string      `json:"mode"`
+	ModTime       time.Time   `json:"mtime"`
+	Lines         int         `json:"lines,omitempty"`
+	Binary        bool        `json:"binary,omitempty"`
+	Skipped       bool        `json:"skipped,omitempty"`        // over Config.MaxFileSize
+	Truncated     bool        `json:"truncated,omitempty"`      // a directory at Config.MaxDepth, or a file cut by the line limits
+	Files         int         `json:"files,omitempty"`          // how many files a truncated directory holds
+	Tokens        *int        `json:"tokens,omitempty"`         // only with Config.ShowTokens
+	GitStatus     string      `json:"git_status,omitempty"`     // only with Config.GitStatus
+	Commit        *jsonCommit `json:"last_commit,omitempty"`    // only with Config.GitLog
+	Nested        string      `json:"nested_repo,omitempty"`    // see Entry.NestedRepo
+	ContinuedFrom int         `json:"continued_from,omitempty"` // the RunSplit part with the lines before these
+	ContinuedIn   int         `json:"continued_in,omitempty"`   // the RunSplit part with the lines after these
+	Content       *string     `json:"content,omitempty"`
+	Diff          *string     `json:"diff,omitempty"` // only with Config.ShowDiff
+	Children      []*jsonNode `json:"children,omitempty"`
+
+	entry Entry // where to stream the content from
+}
+
+// jsonCommit is a file's last commit, for Config.GitLog
+type jsonCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// writeJSON streams the accepted entries as an indented JSON document.
+// Metadata for the whole tree is gathered first; file content (only with
+// cfg.ShowContent) is then streamed from disk while writing. With
+// cfg.ShowTokens files get a "tokens" field and the document a
+// "total_tokens" one.
+func writeJSON(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry) error {
+	return writeWithTokens(ctx, w, cfg, entries, writeJSONBody, writeJSONFooter)
+}
+
+// jsonOmitted describes a file left out to fit Config.MaxTokens
+type jsonOmitted struct {
+	Path   string `json:"path"`
+	Tokens int    `json:"tokens"`
+	Reason string `json:"reason"`
+}
+
+// writeJSONBody writes the document up to its closing brace, with an
+// "omitted" list after the tree when files were dropped for cfg.MaxTokens
+func writeJSONBody(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error {
+	rootNode, err := buildJSONTree(cfg, entries, tokens)
+	if err != nil {
+		return err
+	}
+
+	root, err := json.Marshal(cfg.RootPath)
+	if err != nil {
+		return err
+	}
+	w.WriteString("{\n  \"root\": ")
+	w.Write(root)
+	w.WriteString(",\n  \"tree\": ")
+	if err := writeJSONNode(ctx, w, cfg, rootNode, "  "); err != nil {
+		return err
+	}
+
+	if tokens != nil && len(tokens.omitted) > 0 {
+		w.WriteString(",\n  \"omitted\": [")
+		for i, o := range tokens.omitted {
+			data, err := json.Marshal(jsonOmitted{o.RelPath, o.Tokens, o.Reason})
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				w.WriteString(",")
+			}
+			w.WriteString("\n    ")
+			w.Write(data)
+		}
+		w.WriteString("\n  ]")
+	}
+	return nil
+}
+
+// writeJSONFooter writes the token total and closes the document
+func writeJSONFooter(w *bufio.Writer, cfg *Config, tokens *tokenReport, total int) {
+	if tokens.shown() {
+		fmt.Fprintf(w, ",\n  \"total_tokens\": %d", total)
+	}
+	w.WriteString("\n}\n")
+}
+
+// buildJSONTree collects the metadata of every entry into a tree of nodes
+func buildJSONTree(cfg *Config, entries []Entry, tokens *tokenReport) (*jsonNode, error) {
+	// With cfg.Rev the root comes from the revision, like every other node
+	run := entriesRun(entries)
+	rootInfo, err := statFile(run, cfg.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err := newJSONNode(cfg, Entry{Path: cfg.RootPath, RelPath: ".", IsDir: true, Info: rootInfo, run: run})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]*jsonNode{".": rootNode}
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	// Parents must exist before their children
+	sortEntries(cfg, sorted)
+	sort.SliceStable(sorted, func(i, j int) bool { return pathDepth(sorted[i].RelPath) < pathDepth(sorted[j].RelPath) })
+
+	for _, e := range sorted {
+		node, err := newJSONNode(cfg, e)
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := tokens.count(e.Path); ok {
+			node.Tokens = &n
+		}
+
+		nodes[e.RelPath] = node
+		parent, ok := nodes[path.Dir(e.RelPath)]
+		if !ok {
+			parent = rootNode
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return rootNode, nil
+}
+
+// newJSONNode fills in an entry's metadata. Files also get their line count,
+// as in the file even when the line limits cut it.
+func newJSONNode(cfg *Config, e Entry) (*jsonNode, error) {
+	node := &jsonNode{
+		Name:      e.Info.Name(),
+		Path:      e.RelPath,
+		Type:      "file",
+		Size:      e.Info.Size(),
+		Mode:      e.Info.Mode().String(),
+		ModTime:   e.Info.ModTime(),
+		Binary:    e.Binary,
+		Skipped:   e.Skipped,
+		GitStatus: e.GitStatus,
+		entry:     e,
+	}
+	if e.IsDir {
+		node.Type = "dir"
+		node.Truncated = e.Truncated
+		node.Files = e.Files
+		node.Nested = e.NestedRepo
+		return node, nil
+	}
+	if c := e.LastCommit; c != nil {
+		node.Commit = &jsonCommit{c.Hash, c.Author, c.Date, c.Subject}
+	}
+	if e.Binary || e.Skipped {
+		return node, nil
+	}
+
+	stats, err := scanContent(cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	node.Lines = stats.Lines
+	if lr, ok := e.run.lines(e.Path); ok {
+		node.ContinuedFrom, node.ContinuedIn = lr.before, lr.after
+	}
+	if stats.Total > 0 {
+		node.Lines = stats.Total
+		node.Truncated = true
+	}
+	return node, nil
+}
+
+// writeJSONNode writes a node indented by indent. The metadata is marshalled
+// as usual; content and children are streamed after it.
+func writeJSONNode(ctx context.Context, w *bufio.Writer, cfg *Config, node *jsonNode, indent string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	meta := *node
+	meta.Children = nil
+	data, err := json.MarshalIndent(&meta, indent, "  ")
+	if err != nil {
+		return err
+	}
+	// Reopen the object so more fields can follow
+	w.Write(bytes.TrimSuffix(data, []byte("\n"+indent+"}")))
+
+	field := indent + "  "
+	if cfg.ShowContent && node.Type == "file" && !node.Binary {
+		w.WriteString(",\n" + field + "\"content\": ")
+		if err := writeJSONContent(w, cfg, node.entry); err != nil {
+			return err
+		}
+		if diff := fileDiff(cfg, node.entry); diff != nil {
+			w.WriteString(",\n" + field + "\"diff\": ")
+			if err := writeJSONString(w, bytes.NewReader(diff)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(node.Children) > 0 {
+		w.WriteString(",\n" + field + "\"children\": [")
+		for i, child := range node.Children {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			w.WriteString("\n" + field + "  ")
+			if err := writeJSONNode(ctx, w, cfg, child, field+"  "); err != nil {
+				return err
+			}
+		}
+		w.WriteString("\n" + field + "]")
+	}
+
+	w.WriteString("\n" + indent + "}")
+	return nil
+}
+
+// writeJSONContent streams a file as a JSON string literal
+func writeJSONContent(w *bufio.Writer, cfg *Config, e Entry) error {
+	r, err := openContent(cfg, e)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return writeJSONString(w, r)
+}
+
+// writeJSONString streams r as a JSON string literal
+func writeJSONString(w *bufio.Writer, r io.Reader) error {
+	w.WriteByte('"')
+	br := bufio.NewReader(r)
+	for {
+		c, size, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		writeJSONRune(w, c, size)
+	}
+	w.WriteByte('"')
+	return nil
+}
+
+// writeJSONRune writes a single rune escaped the way encoding/json would
+func writeJSONRune(w *bufio.Writer, c rune, size int) {
+	switch {
+	case c == '"':
+		w.WriteString(`\"`)
+	case c == '\\':
+		w.WriteString(`\\`)
+	case c == '\n':
+		w.WriteString(`\n`)
+	case c == '\r':
+		w.WriteString(`\r`)
+	case c == '\t':
+		w.WriteString(`\t`)
+	case c < 0x20, c == '<', c == '>', c == '&', c == '\u2028', c == '\u2029':
+		fmt.Fprintf(w, `\u%04x`, c)
+	case c == utf8.RuneError && size == 1:
+		// Invalid UTF-8 is replaced, as json.Marshal does
+		w.WriteString(`\ufffd`)
+	default:
+		w.WriteRune(c)
+	}
+}
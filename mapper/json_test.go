@@ -0,0 +1,75 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	tmp := t.TempDir()
+	sub := filepath.Join(tmp, "dir")
+	os.MkdirAll(sub, 0755)
+
+	file1 := filepath.Join(tmp, "file1.txt")
+	os.WriteFile(file1, []byte("line1\nline2"), 0644)
+	file2 := filepath.Join(sub, "file2.md")
+	os.WriteFile(file2, []byte("## doc"), 0644)
+	bin := filepath.Join(tmp, "data.bin")
+	os.WriteFile(bin, []byte{0x00, 0x01}, 0644)
+
+	cfg := &Config{RootPath: tmp, Format: FormatJSON, ShowContent: true}
+	entries := entriesFor(t, tmp, file1, sub, file2, bin)
+	entries[3].Binary = true
+	out := render(t, func(w *bufio.Writer) error {
+		return writeJSON(context.Background(), w, cfg, entries)
+	})
+
+	var doc jsonDocument
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out)
+	}
+	if doc.Root != tmp || doc.Tree.Type != "dir" {
+		t.Fatalf("Unexpected root: %+v", doc)
+	}
+	if len(doc.Tree.Children) != 3 {
+		t.Fatalf("Expected 3 children at the root, got %d", len(doc.Tree.Children))
+	}
+
+	// Children come out sorted by name: data.bin, dir, file1.txt
+	binNode, dirNode, fileNode := doc.Tree.Children[0], doc.Tree.Children[1], doc.Tree.Children[2]
+	if !binNode.Binary || binNode.Content != nil {
+		t.Errorf("Expected data.bin to be flagged binary without content: %+v", binNode)
+	}
+	if dirNode.Type != "dir" || len(dirNode.Children) != 1 || dirNode.Children[0].Path != "dir/file2.md" {
+		t.Errorf("Unexpected dir node: %+v", dirNode)
+	}
+	if fileNode.Lines != 2 || fileNode.Size != 11 || fileNode.Content == nil || *fileNode.Content != "line1\nline2" {
+		t.Errorf("Unexpected file node: %+v", fileNode)
+	}
+	if fileNode.Mode == "" {
+		t.Errorf("Expected a mode string, got %q", fileNode.Mode)
+	}
+
+	// Without --content there is no content field at all
+	cfg.ShowContent = false
+	out = render(t, func(w *bufio.Writer) error {
+		return writeJSON(context.Background(), w, cfg, entriesFor(t, tmp, file1))
+	})
+	doc = jsonDocument{}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tree.Children[0].Content != nil {
+		t.Error("Expected no content without ShowContent")
+	}
+}
+
+func TestRunUnknownFormat(t *testing.T) {
+	if _, err := Run(&Config{RootPath: t.TempDir(), Format: "yaml"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,126 @@
+package mapper
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// languageByExt maps lowercase file extensions to Markdown info strings
+var languageByExt = map[string]string{
+	".bash":   "bash",
+	".c":      "c",
+	".cc":     "cpp",
+	".cpp":    "cpp",
+	".cs":     "csharp",
+	".css":    "css",
+	".dart":   "dart",
+	".fish":   "fish",
+	".go":     "go",
+	".gradle": "groovy",
+	".h":      "c",
+	".hpp":    "cpp",
+	".htm":    "html",
+	".html":   "html",
+	".ini":    "ini",
+	".java":   "java",
+	".js":     "javascript",
+	".json":   "json",
+	".jsx":    "jsx",
+	".kt":     "kotlin",
+	".lua":    "lua",
+	".md":     "markdown",
+	".mjs":    "javascript",
+	".php":    "php",
+	".pl":     "perl",
+	".proto":  "protobuf",
+	".ps1":    "powershell",
+	".py":     "python",
+	".r":      "r",
+	".rb":     "ruby",
+	".rs":     "rust",
+	".scala":  "scala",
+	".scss":   "scss",
+	".sh":     "bash",
+	".sql":    "sql",
+	".swift":  "swift",
+	".tf":     "hcl",
+	".toml":   "toml",
+	".ts":     "typescript",
+	".tsx":    "tsx",
+	".vue":    "vue",
+	".xml":    "xml",
+	".yaml":   "yaml",
+	".yml":    "yaml",
+	".zsh":    "zsh",
+}
+
+// languageByName covers well-known files without a telling extension
+var languageByName = map[string]string{
+	"cmakelists.txt": "cmake",
+	"dockerfile":     "dockerfile",
+	"gnumakefile":    "makefile",
+	"go.mod":         "go",
+	"makefile":       "makefile",
+}
+
+// languageByInterpreter maps shebang interpreters to Markdown info strings
+var languageByInterpreter = map[string]string{
+	"bash":    "bash",
+	"node":    "javascript",
+	"perl":    "perl",
+	"php":     "php",
+	"python":  "python",
+	"ruby":    "ruby",
+	"sh":      "bash",
+	"zsh":     "zsh",
+	"deno":    "typescript",
+	"pwsh":    "powershell",
+	"lua":     "lua",
+	"fish":    "fish",
+	"rscript": "r",
+}
+
+// detectLanguage guesses a file's language from its name, extension or
+// shebang line. It returns "" when nothing matches.
+func detectLanguage(path string, content []byte) string {
+	name := strings.ToLower(filepath.Base(path))
+	if lang, ok := languageByName[name]; ok {
+		return lang
+	}
+	if lang, ok := languageByExt[filepath.Ext(name)]; ok {
+		return lang
+	}
+	return languageFromShebang(content)
+}
+
+// languageFromShebang inspects a "#!" first line, e.g. "#!/usr/bin/env python3"
+func languageFromShebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line := content[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env's own options, e.g. "#!/usr/bin/env -S deno run"
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = filepath.Base(f)
+				break
+			}
+		}
+	}
+
+	// python3, python3.12, ruby2.7 ... all map to their base name
+	interpreter = strings.ToLower(strings.TrimRight(interpreter, "0123456789."))
+	return languageByInterpreter[interpreter]
+}
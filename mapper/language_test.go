@@ -0,0 +1,26 @@
+package mapper
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		path    string
+		content string
+		want    string
+	}{
+		{"main.go", "", "go"},
+		{"dir/App.TSX", "", "tsx"},
+		{"Dockerfile", "FROM alpine", "dockerfile"},
+		{"Makefile", "all:", "makefile"},
+		{"script", "#!/usr/bin/env python3\nprint(1)", "python"},
+		{"run", "#!/bin/sh\necho hi", "bash"},
+		{"tool", "#!/usr/bin/env -S deno run\n", "typescript"},
+		{"notes", "just text", ""},
+		{"data.unknown", "", ""},
+	}
+	for _, c := range cases {
+		if got := detectLanguage(c.path, []byte(c.content)); got != c.want {
+			t.Errorf("detectLanguage(%q) = %q; want %q", c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// ParseByteSize parses sizes such as "500KB", "2mb" or "4096" (bytes)
+func ParseByteSize(s string) (int64, error) {
+	size, err := ParseSplitSize(s)
+	if err != nil || size.Unit != SplitBytes {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 500KB or 2MB)", s)
+	}
+	return int64(size.N), nil
+}
+
+// formatSize formats a byte count for people, e.g. "2.4 MB"
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// tooLarge reports whether a file is over cfg.MaxFileSize
+func tooLarge(cfg *Config, info os.FileInfo) bool {
+	return cfg.MaxFileSize > 0 && info.Size() > cfg.MaxFileSize
+}
+
+// skippedContent is shown instead of the content of a file over
+// cfg.MaxFileSize, which is never read
+func skippedContent(cfg *Config, info os.FileInfo) []byte {
+	return []byte(fmt.Sprintf("... [skipped: %s is over the %s limit] ...\n", formatSize(info.Size()), formatSize(cfg.MaxFileSize)))
+}
+
+// lineLimits returns how many lines to keep from the start and the end of
+// each file. MaxLines alone keeps the first lines; with Head or Tail it
+// caps their sum, the one not given taking the rest. When Head and Tail
+// ask for more than MaxLines, the tail gives way first.
+func lineLimits(cfg *Config) (head, tail int) {
+	head, tail = cfg.Head, cfg.Tail
+	if cfg.MaxLines <= 0 {
+		return head, tail
+	}
+	switch {
+	case head == 0 && tail == 0:
+		head = cfg.MaxLines
+	case head == 0 && tail < cfg.MaxLines:
+		head = cfg.MaxLines - tail
+	case tail == 0 && head < cfg.MaxLines:
+		tail = cfg.MaxLines - head
+	}
+	if head > cfg.MaxLines {
+		head = cfg.MaxLines
+	}
+	if head+tail > cfg.MaxLines {
+		tail = cfg.MaxLines - head
+	}
+	return head, tail
+}
+
+// hasLineLimits reports whether truncateLines changes anything
+func hasLineLimits(cfg *Config) bool {
+	head, tail := lineLimits(cfg)
+	return head > 0 || tail > 0
+}
+
+// limitLines applies the line limits to content. It also returns the line
+// count of content the limits cut (counted like contentStats.Lines), and 0
+// when they leave it as it is.
+func limitLines(cfg *Config, content []byte) ([]byte, int) {
+	head, tail := lineLimits(cfg)
+	if head <= 0 && tail <= 0 {
+		return content, 0
+	}
+	newlines := bytes.Count(content, []byte("\n"))
+	lines := newlines
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++ // a last line without a newline
+	}
+	if lines <= head+tail {
+		return content, 0
+	}
+	return truncateLines(content, head, tail), newlines + 1
+}
+
+// truncateLines keeps the first head and last tail lines of content and
+// replaces the rest with a "... [N lines omitted] ..." marker
+func truncateLines(content []byte, head, tail int) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // content ends with a newline
+	}
+	omitted := len(lines) - head - tail
+	if omitted <= 0 {
+		return content
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines[:head] {
+		buf.Write(line)
+	}
+	if head > 0 && !bytes.HasSuffix(lines[head-1], []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(&buf, "... [%d lines omitted] ...\n", omitted)
+	for _, line := range lines[len(lines)-tail:] {
+		buf.Write(line)
+	}
+	return buf.Bytes()
+}
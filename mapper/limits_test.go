@@ -0,0 +1,130 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for s, want := range map[string]int64{"4096": 4096, "500KB": 500 << 10, "2mb": 2 << 20} {
+		if got, err := ParseByteSize(s); err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "10lines", "1GB", "-5"} {
+		if _, err := ParseByteSize(s); err == nil {
+			t.Errorf("ParseByteSize(%q) should fail", s)
+		}
+	}
+}
+
+func TestLineLimits(t *testing.T) {
+	cases := []struct {
+		maxLines, head, tail int
+		wantHead, wantTail   int
+	}{
+		{0, 0, 0, 0, 0},
+		{100, 0, 0, 100, 0},
+		{100, 0, 20, 80, 20},
+		{100, 30, 0, 30, 70},
+		{100, 30, 20, 30, 20},
+		{0, 10, 5, 10, 5},
+		{10, 8, 8, 8, 2},
+		{5, 0, 10, 0, 5},
+		{5, 7, 0, 5, 0},
+	}
+	for _, c := range cases {
+		head, tail := lineLimits(&Config{MaxLines: c.maxLines, Head: c.head, Tail: c.tail})
+		if head != c.wantHead || tail != c.wantTail {
+			t.Errorf("lineLimits(%d, %d, %d) = %d, %d; want %d, %d", c.maxLines, c.head, c.tail, head, tail, c.wantHead, c.wantTail)
+		}
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	cases := []struct {
+		content    string
+		head, tail int
+		want       string
+	}{
+		{"1\n2\n3\n4\n5\n", 2, 1, "1\n2\n... [2 lines omitted] ...\n5\n"},
+		{"1\n2\n3\n4\n5", 0, 2, "... [3 lines omitted] ...\n4\n5"},
+		{"1\n2\n3\n", 3, 0, "1\n2\n3\n"},
+		{"1\n2\n3\n", 1, 2, "1\n2\n3\n"},
+		{"1\n2\n3", 1, 0, "1\n... [2 lines omitted] ...\n"},
+	}
+	for _, c := range cases {
+		if got := string(truncateLines([]byte(c.content), c.head, c.tail)); got != c.want {
+			t.Errorf("truncateLines(%q, %d, %d) = %q; want %q", c.content, c.head, c.tail, got, c.want)
+		}
+	}
+}
+
+func TestRunWithSizeLimits(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "big.json"), []byte(strings.Repeat("x", 3000)), 0644)
+	os.WriteFile(filepath.Join(tmp, "lines.txt"), []byte("a\nb\nc\nd\ne\n"), 0644)
+
+	out, err := Run(&Config{
+		RootPath:        tmp,
+		ShowTree:        true,
+		ShowContent:     true,
+		SeparateContent: true,
+		MaxFileSize:     2048,
+		MaxLines:        2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "big.json [skipped: 2.9 KB]\n") || strings.Contains(out, "xxx") {
+		t.Errorf("big.json should be listed without content:\n%s", out)
+	}
+	if !strings.Contains(out, "... [skipped: 2.9 KB is over the 2.0 KB limit] ...\n") {
+		t.Errorf("missing skipped marker:\n%s", out)
+	}
+	if !strings.Contains(out, "a\nb\n... [3 lines omitted] ...\n") || strings.Contains(out, "e\n") {
+		t.Errorf("lines.txt not truncated:\n%s", out)
+	}
+
+	// Headers give the line count of the file, not of what's left of it,
+	// and none for skipped files
+	if !strings.Contains(out, "lines.txt (6 lines, truncated):\n") {
+		t.Errorf("missing truncated header:\n%s", out)
+	}
+	if !strings.Contains(out, "big.json:\n") {
+		t.Errorf("skipped file header shouldn't count lines:\n%s", out)
+	}
+
+	out, err = Run(&Config{RootPath: tmp, Format: FormatJSON, MaxFileSize: 2048, MaxLines: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"lines": 6,`) || !strings.Contains(out, `"truncated": true`) {
+		t.Errorf("JSON should give the full line count of a truncated file:\n%s", out)
+	}
+	if strings.Count(out, `"lines"`) != 1 {
+		t.Errorf("skipped files shouldn't have a line count:\n%s", out)
+	}
+}
+
+func TestLimitLines(t *testing.T) {
+	cases := []struct {
+		content  string
+		maxLines int
+		total    int
+	}{
+		{"a\nb\nc\n", 0, 0},
+		{"a\nb\nc\n", 3, 0},
+		{"a\nb\nc", 3, 0},
+		{"a\nb\nc\n", 2, 4},
+		{"a\nb\nc", 2, 3},
+	}
+	for _, c := range cases {
+		data, total := limitLines(&Config{MaxLines: c.maxLines}, []byte(c.content))
+		if total != c.total || (total == 0) != (string(data) == c.content) {
+			t.Errorf("limitLines(%q, %d) = %q, %d; want total %d", c.content, c.maxLines, data, total, c.total)
+		}
+	}
+}
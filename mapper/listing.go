@@ -0,0 +1,504 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Run is the main entry point for listing logic.
+// It returns a string containing the final output (tree or flat + optional content).
+// It's a thin wrapper around RunTo for callers that want the output in memory.
+func Run(cfg *Config) (string, error) {
+	var sb strings.Builder
+	if err := RunTo(context.Background(), cfg, &sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// RunTo walks cfg.RootPath and streams the rendered output to w.
+// File contents are copied from disk one file at a time, so memory use
+// doesn't grow with the size of the dump. Cancelling ctx stops the walk
+// and the rendering between files.
+//
+// The output is produced by cfg.Renderer if set, otherwise by the built-in
+// renderer for cfg.Format.
+func RunTo(ctx context.Context, cfg *Config, w io.Writer) error {
+	renderer, err := chooseRenderer(cfg)
+	if err != nil {
+		return err
+	}
+
+	entries, err := Walk(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	// A revision's files are read through one git process
+	stop := entriesRun(entries).revTree().batch()
+	defer stop()
+	return renderer.Render(ctx, w, cfg, entries)
+}
+
+// Entry is a single path accepted by Walk
+type Entry struct {
+	Path    string      // the walked path, i.e. joined with cfg.RootPath
+	RelPath string      // slash-separated path relative to cfg.RootPath
+	IsDir   bool        // directories are listed so the tree can be rebuilt
+	Binary  bool        // only set for FormatJSON, which keeps binary files
+	Skipped bool        // over Config.MaxFileSize, so listed without content
+	Info    fs.FileInfo // size, mode and modification time
+
+	// Directories at Config.MaxDepth are listed without their contents
+	Truncated bool // set for such a directory holding files
+	Files     int  // how many files a truncated directory holds
+
+	GitStatus  string  // with Config.GitStatus, one of the Git* constants if changed or ignored
+	LastCommit *Commit // with Config.GitLog, the last commit changing a file, if any
+	NestedRepo string  // for a directory holding another repository, one of the Nested* constants
+
+	run *walkRun // where the content comes from; see OpenContent
+}
+
+// walkRun is what a call to Walk found that reading content depends on.
+// Every entry of the run points to it, so Config is never changed and
+// can be shared between runs.
+type walkRun struct {
+	tree    *gitTree                 // Config.Rev's tree
+	changes *gitChanges              // for Config.ChangedSince, Config.Staged and Config.Unstaged
+	ranges  map[string]lineRange     // set by RunSplit for files spread over parts
+	secrets map[string][]secretMatch // in the content of the files checked for secrets, by path
+}
+
+// revTree returns the tree content is read from, or nil for the working tree
+func (run *walkRun) revTree() *gitTree {
+	if run == nil {
+		return nil
+	}
+	return run.tree
+}
+
+// lines returns the lines of the file at path to show, if RunSplit spread
+// it over several parts
+func (run *walkRun) lines(path string) (lineRange, bool) {
+	if run == nil {
+		return lineRange{}, false
+	}
+	lr, ok := run.ranges[path]
+	return lr, ok
+}
+
+// secretMatches returns the secrets Walk found in the shown content of the
+// file at path, and whether it looked for them
+func (run *walkRun) secretMatches(path string) ([]secretMatch, bool) {
+	if run == nil {
+		return nil, false
+	}
+	matches, ok := run.secrets[path]
+	return matches, ok
+}
+
+// change returns how the file at path changed, or nil
+func (run *walkRun) change(path string) *fileChange {
+	if run == nil {
+		return nil
+	}
+	return run.changes.file(path)
+}
+
+// Kinds of repositories nested in the tree, for Entry.NestedRepo
+const (
+	NestedSubmodule = "submodule"
+	NestedWorktree  = "worktree" // a linked worktree checked out inside the tree
+	NestedRepo      = "repo"     // an independent repository
+)
+
+// Walk walks cfg.RootPath and returns every accepted directory and file in
+// listing order: each directory is followed by its contents, siblings
+// sorted by cfg.Sort (by name by default). It applies all of cfg's filters
+// but renders nothing. With cfg.Rev the revision's tree is listed instead of
+// the working tree, and the entries remember it so OpenContent reads from it.
+func Walk(ctx context.Context, cfg *Config) ([]Entry, error) {
+	return walkEntries(ctx, cfg, false)
+}
+
+// walkEntries implements Walk. With split, the parts RunSplit writes next to
+// cfg.Output are left out too.
+func walkEntries(ctx context.Context, cfg *Config, split bool) ([]Entry, error) {
+	includePatterns := splitPatterns(cfg.Include)
+	excludePatterns := splitPatterns(cfg.Exclude)
+
+	includeRegex, err := compileRegex(cfg.IncludeRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include regex: %v", err)
+	}
+	excludeRegex, err := compileRegex(cfg.ExcludeRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude regex: %v", err)
+	}
+
+	secrets := secretsMode(cfg)
+	switch secrets {
+	case SecretsOff, SecretsRedact, SecretsSkipFile, SecretsFail:
+	default:
+		return nil, fmt.Errorf("unknown secrets mode %q", cfg.Secrets)
+	}
+
+	if _, err := metaColumns(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := sortKey(cfg); err != nil {
+		return nil, err
+	}
+
+	// Build a set of Git-tracked files if needed
+	var trackedFiles map[string]bool
+	if cfg.GitTrackedOnly && cfg.Rev == "" {
+		trackedFiles, err = getGitTrackedFiles(ctx, cfg.RootPath, cfg.RecurseSubmodules)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Git-tracked files: %v", err)
+		}
+	}
+
+	// Load .gitignore, info/exclude and core.excludesFile patterns if requested
+	absRoot, err := filepath.Abs(cfg.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	// Walk the root directory, or the tree of cfg.Rev
+	run := &walkRun{secrets: make(map[string][]secretMatch)}
+	walk := func(fn filepath.WalkFunc) error { return filepath.Walk(cfg.RootPath, fn) }
+	var openIgnore func(path string) (io.ReadCloser, error) // ignore files too
+	if cfg.Rev != "" {
+		tree, err := loadGitTree(ctx, cfg.RootPath, cfg.Rev, cfg.RecurseSubmodules)
+		if err != nil {
+			return nil, err
+		}
+		run.tree = tree
+		walk = tree.walk
+		openIgnore = tree.openIgnoreFile
+	}
+
+	var ignores []*ignoreMatcher
+	if cfg.UseGitignore {
+		gitignore, err := newGitignoreMatcher(cfg.RootPath, openIgnore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load .gitignore files: %v", err)
+		}
+		ignores = append(ignores, gitignore)
+	}
+
+	// .filemapperignore files are always honored, plus any --ignore-file
+	fmIgnore, err := newFileMapperIgnoreMatcher(absRoot, splitPatterns(cfg.IgnoreFiles), openIgnore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ignore file: %v", err)
+	}
+	ignores = append(ignores, fmIgnore)
+
+	// The output may be written inside the root while it's walked
+	var output string
+	if cfg.Output != "" && cfg.Rev == "" {
+		if output, err = filepath.Abs(cfg.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	// We'll store all "accepted" paths
+	var entries []Entry
+
+	// Files below cfg.MaxDepth, which are still walked, but only counted
+	var deep []Entry
+
+	// Only changed files are listed with ChangedSince, Staged or Unstaged
+	run.changes, err = loadGitChanges(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walk(func(path string, info fs.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Skip the root path in listing output, but still descend
+		if path == cfg.RootPath {
+			return loadIgnoreDir(ignores, absRoot)
+		}
+
+		// If hidden (e.g. ".git"), skip
+		if isHidden(path, cfg.RootPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// If user-specified excludes match, skip
+		if shouldExclude(path, info, excludePatterns, cfg.RootPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(cfg.RootPath, path)
+		if err != nil {
+			return err
+		}
+
+		// If the exclude regex matches the relative path, skip.
+		// Directories are matched with a trailing slash (e.g. "pkg/testdata/").
+		if excludeRegex != nil && excludeRegex.MatchString(regexSubject(rel, info.IsDir())) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// If ignored by a .gitignore, .filemapperignore or --ignore-file, skip
+		absPath := filepath.Join(absRoot, rel)
+		if isIgnoredByAny(ignores, absPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Don't list our own output (or its parts) as one of the files
+		if output != "" && !info.IsDir() && isOutputFile(output, absPath, split) {
+			return nil
+		}
+
+		// **Key Fix**: Handle directories separately so we always descend.
+		if info.IsDir() {
+			// Patterns from this directory's ignore files apply to everything below it
+			if err := loadIgnoreDir(ignores, absPath); err != nil {
+				return err
+			}
+
+			depth := pathDepth(rel)
+			if cfg.MaxDepth > 0 && depth > cfg.MaxDepth {
+				return nil
+			}
+
+			// We can list the directory if we want it to appear in the final tree,
+			// or skip it if we prefer only to show files.
+			nested := nestedRepo(run.tree, path)
+			entries = append(entries, Entry{Path: path, RelPath: filepath.ToSlash(rel), IsDir: true, Info: info, NestedRepo: nested, run: run})
+
+			// Git doesn't track what's inside other repositories, except
+			// for submodules when asked to recurse
+			if trackedFiles != nil && nested != "" && (nested != NestedSubmodule || !cfg.RecurseSubmodules) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Now it's a file:
+		// If Git-tracked-only, skip files that aren't tracked.
+		// Everything in a revision is.
+		if trackedFiles != nil && !trackedFiles[filepath.ToSlash(rel)] {
+			return nil
+		}
+		if run.changes != nil && run.change(path) == nil {
+			return nil
+		}
+
+		// Check if it matches the include patterns
+		if len(includePatterns) > 0 && !matchesAnyPattern(filepath.ToSlash(rel), includePatterns) {
+			return nil
+		}
+
+		// Check if it matches the include regex
+		if includeRegex != nil && !includeRegex.MatchString(regexSubject(rel, false)) {
+			return nil
+		}
+
+		// Content checks come once every candidate is known, since a
+		// revision's content is read in one batch. Files below cfg.MaxDepth
+		// only add to their directory's count.
+		e := Entry{Path: path, RelPath: filepath.ToSlash(rel), Info: info, run: run}
+		if cfg.MaxDepth > 0 && pathDepth(rel) > cfg.MaxDepth {
+			deep = append(deep, e)
+		} else {
+			entries = append(entries, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err = checkContent(ctx, cfg, run, entries, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only files that would be listed count, but their content isn't shown
+	if len(deep) > 0 {
+		if deep, err = checkContent(ctx, cfg, run, deep, SecretsOff); err != nil {
+			return nil, err
+		}
+		countTruncated(cfg, entries, deep)
+	}
+
+	// Directories without changes would only clutter the listing
+	if run.changes != nil {
+		changed := make(map[string]bool)
+		for _, path := range filePaths(entries) {
+			changed[path] = true
+		}
+		entries = keepEntries(entries, changed, false)
+	}
+
+	if cfg.GitStatus {
+		if err := annotateGitStatus(ctx, cfg, entries); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.GitLog {
+		if err := annotateLastCommits(ctx, cfg, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	sortEntries(cfg, entries)
+	return entries, nil
+}
+
+// checkContent drops the binary files (except for FormatJSON, which keeps
+// them flagged, without content, so consumers see the whole tree) and looks
+// for credentials in the rest before any content is written
+func checkContent(ctx context.Context, cfg *Config, run *walkRun, entries []Entry, secrets string) ([]Entry, error) {
+	stop := run.tree.batch()
+	defer stop()
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.IsDir {
+			kept = append(kept, e)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		e.Binary = isBinaryFile(run, e.Path)
+		if e.Binary && cfg.Format != FormatJSON {
+			continue
+		}
+		e.Skipped = tooLarge(cfg, e.Info)
+
+		if cfg.ShowContent && !e.Binary && !e.Skipped && secrets != SecretsOff {
+			matches, found, err := checkSecrets(cfg, e, secrets)
+			if err != nil {
+				return nil, err
+			}
+			if found && secrets == SecretsSkipFile {
+				continue
+			}
+			run.secrets[e.Path] = matches
+		}
+		kept = append(kept, e)
+	}
+	return kept, nil
+}
+
+// isOutputFile reports whether path is the output file or, with split, one
+// of the parts RunSplit writes next to it (see ChunkPath)
+func isOutputFile(output, path string, split bool) bool {
+	if path == output {
+		return true
+	}
+	if !split {
+		return false
+	}
+	ext := filepath.Ext(output)
+	prefix := strings.TrimSuffix(output, ext) + "."
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, ext) || len(path) < len(prefix)+len(ext)+3 {
+		return false
+	}
+	part := path[len(prefix) : len(path)-len(ext)]
+	for _, c := range part {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// countTruncated marks the directories at cfg.MaxDepth holding some of the
+// deep files, which are below that depth, and counts the files they hold
+func countTruncated(cfg *Config, entries, deep []Entry) {
+	dirs := make(map[string]int)
+	for i, e := range entries {
+		if e.IsDir && pathDepth(e.RelPath) == cfg.MaxDepth {
+			dirs[e.RelPath] = i
+		}
+	}
+	for _, e := range deep {
+		top := strings.Join(strings.Split(e.RelPath, "/")[:cfg.MaxDepth], "/")
+		if i, ok := dirs[top]; ok {
+			entries[i].Truncated = true
+			entries[i].Files++
+		}
+	}
+}
+
+// pathDepth returns how many levels below the root rel is; the root's
+// children are at depth 1
+func pathDepth(rel string) int {
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+// splitPatterns takes a comma-separated string of patterns and splits them
+func splitPatterns(patterns string) []string {
+	if patterns == "" {
+		return nil
+	}
+	parts := strings.Split(patterns, ",")
+	for i := 0; i < len(parts); i++ {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// getGitTrackedFiles uses "git ls-files" to list tracked files, relative to
+// root, including those of submodules if recurse is set
+func getGitTrackedFiles(ctx context.Context, root string, recurse bool) (map[string]bool, error) {
+	args := []string{"ls-files", "-z"}
+	if recurse {
+		args = append(args, "--recurse-submodules")
+	}
+	out, err := gitOutput(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]bool)
+	for _, rel := range strings.Split(string(out), "\x00") {
+		if rel != "" {
+			tracked[rel] = true
+		}
+	}
+	return tracked, nil
+}
+
+// nestedRepo returns the kind of repository whose top is the directory at
+// path, if any. In a revision's tree only submodules are known.
+func nestedRepo(tree *gitTree, path string) string {
+	if tree != nil {
+		if f := tree.files[path]; f != nil && f.submodule {
+			return NestedSubmodule
+		}
+		return ""
+	}
+	return nestedRepoKind(path)
+}
@@ -0,0 +1,299 @@
+package mapper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSplitPatterns ensures splitPatterns works as expected
+func TestSplitPatterns(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"*.go", []string{"*.go"}},
+		{"*.go, *.md", []string{"*.go", "*.md"}},
+	}
+	for _, c := range cases {
+		got := splitPatterns(c.input)
+		if len(got) != len(c.expected) {
+			t.Errorf("splitPatterns(%q) length = %d; want %d", c.input, len(got), len(c.expected))
+			continue
+		}
+		for i := range got {
+			if got[i] != c.expected[i] {
+				t.Errorf("splitPatterns(%q)[%d] = %q; want %q", c.input, i, got[i], c.expected[i])
+			}
+		}
+	}
+}
+
+// TestRunBasic uses a small in-memory structure to test listing logic
+func TestRunBasic(t *testing.T) {
+	tmp := t.TempDir()
+	// make subdir
+	sub := filepath.Join(tmp, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// create a text file
+	txt1 := filepath.Join(tmp, "hello.txt")
+	_ = ioutil.WriteFile(txt1, []byte("hello world"), 0644)
+
+	// create a binary file
+	bin1 := filepath.Join(tmp, "binary.bin")
+	_ = ioutil.WriteFile(bin1, []byte{0x00, 0x01, 0x02}, 0644)
+
+	// create hidden file
+	hidden := filepath.Join(tmp, ".gitignore")
+	_ = ioutil.WriteFile(hidden, []byte("ignore stuff"), 0644)
+
+	cfg := &Config{
+		RootPath:          tmp,
+		Include:           "", // no pattern
+		Exclude:           "", // no exclude
+		GitTrackedOnly:    false,
+		ShowTree:          true,
+		ShowContent:       true,
+		SeparateContent:   false,
+		Output:            "",
+		ShowLineNumbers:   false,
+		ShowHeaderFooters: true,
+	}
+
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+
+	// Expect "hello.txt" content, not binary.bin, not .gitignore
+	if !strings.Contains(out, "hello.txt") {
+		t.Error("Expected hello.txt to appear in output.")
+	}
+	if strings.Contains(out, "binary.bin") {
+		t.Error("Expected binary.bin to be skipped.")
+	}
+	if strings.Contains(out, ".gitignore") {
+		t.Error("Expected hidden file to be skipped.")
+	}
+}
+
+// TestRunPathPatterns checks that include/exclude patterns with a slash
+// are matched against the root-relative path
+func TestRunPathPatterns(t *testing.T) {
+	tmp := t.TempDir()
+	for _, rel := range []string{"internal/a/x.go", "internal/b.go", "cmd/main.go", "docs/generated/api.md", "docs/intro.md"} {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		_ = ioutil.WriteFile(full, []byte("content"), 0644)
+	}
+
+	cfg := &Config{
+		RootPath: tmp,
+		Include:  "internal/**/*.go,*.md",
+		Exclude:  "docs/generated",
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	for _, want := range []string{"x.go", "b.go", "intro.md"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"main.go", "api.md", "generated"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Expected %s to be filtered out:\n%s", unwanted, out)
+		}
+	}
+}
+
+// TestRunRegexFilters checks --include-regex and --exclude-regex
+func TestRunRegexFilters(t *testing.T) {
+	tmp := t.TempDir()
+	for _, rel := range []string{"cmd/app/main.go", "pkg/api.proto", "pkg/api_test.go", "pkg/testdata/in.go", "scripts/run.go"} {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		_ = ioutil.WriteFile(full, []byte("content"), 0644)
+	}
+
+	cfg := &Config{
+		RootPath:     tmp,
+		ShowTree:     false,
+		IncludeRegex: `^(cmd|pkg)/.*\.(go|proto)$`,
+		ExcludeRegex: `_test\.go$|(^|/)testdata/`,
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	for _, want := range []string{"main.go", "api.proto"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in output:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"api_test.go", "testdata", "run.go"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Expected %s to be filtered out:\n%s", unwanted, out)
+		}
+	}
+
+	cfg.IncludeRegex = "("
+	if _, err := Run(cfg); err == nil {
+		t.Error("Expected an error for an invalid include regex")
+	}
+}
+
+// TestRunToStreamsAndCancels checks RunTo writes to the given writer and
+// stops when the context is cancelled
+func TestRunToStreamsAndCancels(t *testing.T) {
+	tmp := t.TempDir()
+	_ = ioutil.WriteFile(filepath.Join(tmp, "a.txt"), []byte("alpha"), 0644)
+
+	var sb strings.Builder
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowContent: true, SeparateContent: true}
+	if err := RunTo(context.Background(), cfg, &sb); err != nil {
+		t.Fatalf("RunTo error: %v", err)
+	}
+	if !strings.Contains(sb.String(), "alpha") {
+		t.Errorf("Expected streamed content, got:\n%s", sb.String())
+	}
+
+	// Run is a wrapper around RunTo and must produce the same output
+	out, err := Run(cfg)
+	if err != nil || out != sb.String() {
+		t.Errorf("Expected Run to match RunTo, got %q, %v", out, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunTo(ctx, cfg, &sb); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestWalkEntries checks the typed entries returned by Walk
+func TestWalkEntries(t *testing.T) {
+	tmp := t.TempDir()
+	os.Mkdir(filepath.Join(tmp, "sub"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(tmp, "sub", "a.txt"), []byte("alpha"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(tmp, "data.bin"), []byte{0x00, 0x01}, 0644)
+
+	entries, err := Walk(context.Background(), &Config{RootPath: tmp})
+	if err != nil {
+		t.Fatalf("Walk error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries (binary skipped), got %+v", entries)
+	}
+	if entries[0].RelPath != "sub" || !entries[0].IsDir {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].RelPath != "sub/a.txt" || entries[1].IsDir || entries[1].Info.Size() != 5 {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+	}
+	if entries[1].Path != filepath.Join(tmp, "sub", "a.txt") {
+		t.Errorf("Expected Path joined with the root, got %q", entries[1].Path)
+	}
+
+	// JSON keeps binary files, flagged
+	entries, err = Walk(context.Background(), &Config{RootPath: tmp, Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].RelPath != "data.bin" || !entries[0].Binary {
+		t.Errorf("Expected a flagged data.bin entry, got %+v", entries)
+	}
+}
+
+// TestRunMaxDepth checks that directories at MaxDepth are listed with their
+// file count but not expanded
+func TestRunMaxDepth(t *testing.T) {
+	tmp := t.TempDir()
+	for _, rel := range []string{"top.txt", "a/one.txt", "a/b/two.txt", "a/b/c/three.txt", "a/skip.log", "empty/x/.hidden"} {
+		path := filepath.Join(tmp, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(rel), 0644)
+	}
+	// Binary files aren't listed, so they aren't counted either
+	os.WriteFile(filepath.Join(tmp, "a", "b", "data.bin"), []byte{0, 1, 2}, 0644)
+
+	cfg := &Config{RootPath: tmp, Exclude: "*.log", MaxDepth: 1, ShowTree: true}
+	entries, err := Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.RelPath)
+		if e.RelPath == "a" && (!e.Truncated || e.Files != 3) {
+			t.Errorf("a: Truncated = %v, Files = %d; want true, 3", e.Truncated, e.Files)
+		}
+		if e.RelPath == "empty" && e.Truncated {
+			t.Error("a directory without files shouldn't be marked truncated")
+		}
+	}
+	if want := "a,empty,top.txt"; strings.Join(got, ",") != want {
+		t.Errorf("Walk with MaxDepth 1 = %v; want %s", got, want)
+	}
+
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "├── a/ (3 files, not expanded)\n") {
+		t.Errorf("missing truncated directory summary:\n%s", out)
+	}
+
+	cfg.MaxDepth = 2
+	cfg.ShowTree = false
+	if out, err = Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, filepath.Join(tmp, "a", "b")+"/ (2 files, not expanded)\n") || !strings.Contains(out, "one.txt") {
+		t.Errorf("unexpected flat output at depth 2:\n%s", out)
+	}
+
+	cfg.MaxDepth = 3
+	if out, err = Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, filepath.Join(tmp, "a", "b", "c")+"/ (1 file, not expanded)\n") {
+		t.Errorf("unexpected flat output at depth 3:\n%s", out)
+	}
+}
+
+func TestIsOutputFile(t *testing.T) {
+	output := filepath.Join("root", "out.txt")
+	tests := []struct {
+		path  string
+		split bool
+		want  bool
+	}{
+		{output, false, true},
+		{output, true, true},
+		{filepath.Join("root", "out.001.txt"), true, true},
+		{filepath.Join("root", "out.1234.txt"), true, true},
+		{filepath.Join("root", "out.001.txt"), false, false}, // only parts when splitting
+		{filepath.Join("root", "out.01.txt"), true, false},
+		{filepath.Join("root", "out.abc.txt"), true, false},
+		{filepath.Join("root", "out.md"), true, false},
+		{filepath.Join("sub", "out.txt"), false, false},
+	}
+	for _, tt := range tests {
+		if got := isOutputFile(output, tt.path, tt.split); got != tt.want {
+			t.Errorf("isOutputFile(%q, split %v) = %v; want %v", tt.path, tt.split, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// writeMarkdown renders the tree (or flat list) in a fenced block and,
+// if cfg.ShowContent is set, each file as a "### path" heading followed by a
+// fenced block tagged with the detected language (and a "diff" block with
+// cfg.ShowDiff). Content is always listed
+// after the tree, since fenced blocks can't be nested inside it. With
+// cfg.ShowTokens the listing carries token counts and a total follows.
+func writeMarkdown(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry) error {
+	return writeWithTokens(ctx, w, cfg, entries, writeMarkdownBody, writeMarkdownFooter)
+}
+
+// writeMarkdownFooter writes the token total
+func writeMarkdownFooter(w *bufio.Writer, cfg *Config, tokens *tokenReport, total int) {
+	if tokens.shown() {
+		fmt.Fprintf(w, "\n**Total:** %d tokens (%d files)\n", total, tokens.keptFiles())
+	}
+}
+
+// writeMarkdownBody writes everything of the Markdown format but the token
+// total, ending with the files omitted to fit cfg.MaxTokens
+func writeMarkdownBody(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error {
+	listing, files := buildPlainListing(cfg, entries, tokens)
+	fence := markdownFence(longestBacktickRun(listing))
+	w.WriteString(fence + "\n")
+	w.WriteString(listing)
+	w.WriteString(fence + "\n")
+
+	if cfg.ShowContent {
+		if err := writeMarkdownContent(ctx, w, cfg, files); err != nil {
+			return err
+		}
+	}
+
+	if tokens != nil && len(tokens.omitted) > 0 {
+		fmt.Fprintf(w, "\n### Omitted to fit %d tokens\n\n", cfg.MaxTokens)
+		for _, o := range tokens.omitted {
+			fmt.Fprintf(w, "- %s (%d tokens): %s\n", o.Path, o.Tokens, o.Reason)
+		}
+	}
+	return nil
+}
+
+// writeMarkdownContent writes each file under its heading
+func writeMarkdownContent(ctx context.Context, w *bufio.Writer, cfg *Config, files []Entry) error {
+
+	for _, e := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := e.Path
+		info, err := statFile(e.run, path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// The fence length and language have to be known up front
+		r, stats, err := openContentStats(cfg, e)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n### %s\n\n", path)
+		if commit := commitSummary(e.LastCommit); commit != "" {
+			fmt.Fprintf(w, "Last commit: %s\n\n", commit)
+		}
+		lr, _ := e.run.lines(path)
+		if marker := continuedMarker("from", lr.before); marker != "" {
+			w.WriteString(marker + "\n")
+		}
+		fence := markdownFence(stats.LongestBackticks)
+		w.WriteString(fence + detectLanguage(path, stats.Head) + "\n")
+		if cfg.ShowLineNumbers {
+			err = writeLines(w, cfg, r, "", lr.from+1)
+		} else if info.Size() > 0 {
+			err = copyContent(w, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+		w.WriteString(fence + "\n")
+		if marker := continuedMarker("in", lr.after); marker != "" {
+			w.WriteString("\n" + marker)
+		}
+
+		if diff := fileDiff(cfg, e); diff != nil {
+			fence := markdownFence(longestBacktickRun(string(diff)))
+			w.WriteString("\n" + fence + "diff\n")
+			w.Write(diff)
+			w.WriteString(fence + "\n")
+		}
+	}
+	return nil
+}
+
+// markdownFence returns a backtick fence longer than the longest backtick
+// run in the content (and at least three long), so it can never close early.
+func markdownFence(longestRun int) string {
+	if longestRun < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longestRun+1)
+}
+
+// longestBacktickRun returns the length of the longest run of backticks in text
+func longestBacktickRun(text string) int {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
@@ -0,0 +1,59 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownFence(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"plain", "```"},
+		{"inline `code` here", "```"},
+		{"```go\nx\n```", "````"},
+		{"`````", "``````"},
+	}
+	for _, c := range cases {
+		if got := markdownFence(longestBacktickRun(c.text)); got != c.want {
+			t.Errorf("markdownFence(%q) = %q; want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	tmp := t.TempDir()
+	goFile := filepath.Join(tmp, "main.go")
+	os.WriteFile(goFile, []byte("package main\n"), 0644)
+	mdFile := filepath.Join(tmp, "README.md")
+	os.WriteFile(mdFile, []byte("```bash\nmake\n```\n"), 0644)
+
+	cfg := &Config{
+		RootPath:          tmp,
+		Format:            FormatMarkdown,
+		ShowTree:          true,
+		ShowContent:       true,
+		ShowHeaderFooters: true,
+	}
+	out := render(t, func(w *bufio.Writer) error {
+		return writeMarkdown(context.Background(), w, cfg, entriesFor(t, tmp, mdFile, goFile))
+	})
+
+	if !strings.HasPrefix(out, "```\n├── README.md\n└── main.go\n```\n") {
+		t.Errorf("Expected the tree in a fenced block, got:\n%s", out)
+	}
+	if !strings.Contains(out, "### "+goFile+"\n\n```go\npackage main\n```\n") {
+		t.Errorf("Expected a go-tagged block for main.go, got:\n%s", out)
+	}
+	if !strings.Contains(out, "````markdown\n```bash\nmake\n```\n````\n") {
+		t.Errorf("Expected a longer fence around README.md, got:\n%s", out)
+	}
+	if strings.Contains(out, "CONTENT START") {
+		t.Error("Expected no text-format content markers in markdown")
+	}
+}
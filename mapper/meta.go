@@ -0,0 +1,170 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Metadata columns accepted by Config.Meta
+const (
+	MetaSize  = "size"  // human-readable size
+	MetaLines = "lines" // line count, as wc -l reports it
+	MetaMtime = "mtime" // last modification time
+	MetaMode  = "mode"  // permission bits
+)
+
+// metaColumns returns the columns in cfg.Meta, in order; "all" selects
+// every column
+func metaColumns(cfg *Config) ([]string, error) {
+	var columns []string
+	for _, name := range splitPatterns(cfg.Meta) {
+		switch name {
+		case "all":
+			columns = append(columns, MetaSize, MetaLines, MetaMtime, MetaMode)
+		case MetaSize, MetaLines, MetaMtime, MetaMode:
+			columns = append(columns, name)
+		default:
+			return nil, fmt.Errorf("unknown metadata column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// entryMeta is what the metadata columns show for a file, or the totals
+// of the files below a directory
+type entryMeta struct {
+	size  int64
+	lines int
+	mtime time.Time // the newest one for directories
+}
+
+func (m *entryMeta) add(o entryMeta) {
+	m.size += o.size
+	m.lines += o.lines
+	if o.mtime.After(m.mtime) {
+		m.mtime = o.mtime
+	}
+}
+
+// entryMetas returns the metadata of every entry by Entry.Path. Directories
+// get the totals of the files listed below them. Lines are only counted if
+// countLines is set, and not for files over Config.MaxFileSize, which are
+// never read.
+func entryMetas(cfg *Config, entries []Entry, countLines bool) map[string]entryMeta {
+	dirs := make(map[string]*entryMeta)
+	metas := make(map[string]entryMeta)
+	for _, e := range entries {
+		if e.IsDir {
+			continue
+		}
+		m := entryMeta{size: e.Info.Size(), mtime: e.Info.ModTime()}
+		if countLines && !e.Binary && !e.Skipped {
+			m.lines = lineCount(e.run, e.Path)
+		}
+		metas[e.Path] = m
+		for dir := path.Dir(e.RelPath); dir != "."; dir = path.Dir(dir) {
+			if dirs[dir] == nil {
+				dirs[dir] = &entryMeta{}
+			}
+			dirs[dir].add(m)
+		}
+	}
+
+	for _, e := range entries {
+		if e.IsDir {
+			m := entryMeta{mtime: e.Info.ModTime()}
+			if total := dirs[e.RelPath]; total != nil {
+				m = *total
+			}
+			metas[e.Path] = m
+		}
+	}
+	return metas
+}
+
+// metaLabels returns the metadata annotation of every entry by Entry.Path,
+// e.g. " [4.2 KB, 120 lines]". Directories show the totals of the files
+// listed below them; truncated ones, whose files aren't read, show no size
+// or line count.
+func metaLabels(cfg *Config, entries []Entry) map[string]string {
+	columns, _ := metaColumns(cfg)
+	if len(columns) == 0 {
+		return nil
+	}
+	countLines := false
+	for _, c := range columns {
+		countLines = countLines || c == MetaLines
+	}
+	metas := entryMetas(cfg, entries, countLines)
+
+	labels := make(map[string]string)
+	for _, e := range entries {
+		m := metas[e.Path]
+		var fields []string
+		for _, c := range columns {
+			switch c {
+			case MetaSize:
+				if !e.Truncated {
+					fields = append(fields, formatSize(m.size))
+				}
+			case MetaLines:
+				if !e.Binary && !e.Truncated && !e.Skipped {
+					fields = append(fields, plural(m.lines, "line"))
+				}
+			case MetaMtime:
+				fields = append(fields, m.mtime.Format("2006-01-02 15:04"))
+			case MetaMode:
+				fields = append(fields, e.Info.Mode().String())
+			}
+		}
+		if len(fields) > 0 {
+			labels[e.Path] = " [" + strings.Join(fields, ", ") + "]"
+		}
+	}
+	return labels
+}
+
+// plural formats a count with a noun, e.g. "1 line" or "120 lines"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// lineCount counts the lines of a walked file like wc -l, plus a last
+// line without a newline
+func lineCount(run *walkRun, path string) int {
+	f, _, err := openFile(run, path)
+	if err != nil || f == nil {
+		return 0
+	}
+	defer f.Close()
+
+	n := 0
+	last := byte('\n')
+	r := bufio.NewReaderSize(f, 32*1024)
+	buf := make([]byte, 32*1024)
+	for {
+		k, err := r.Read(buf)
+		if k > 0 {
+			n += bytes.Count(buf[:k], []byte("\n"))
+			last = buf[k-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n
+		}
+	}
+	if last != '\n' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetaColumns(t *testing.T) {
+	got, err := metaColumns(&Config{Meta: "lines, size"})
+	if err != nil || strings.Join(got, ",") != "lines,size" {
+		t.Errorf("metaColumns = %v, %v", got, err)
+	}
+	if got, _ := metaColumns(&Config{Meta: "all"}); len(got) != 4 {
+		t.Errorf("all = %v", got)
+	}
+	if _, err := metaColumns(&Config{Meta: "size,owner"}); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestLineCount(t *testing.T) {
+	tmp := t.TempDir()
+	for content, want := range map[string]int{"": 0, "a": 1, "a\n": 1, "a\nb": 2, "a\n\n": 2} {
+		path := filepath.Join(tmp, "f")
+		os.WriteFile(path, []byte(content), 0644)
+		if got := lineCount(nil, path); got != want {
+			t.Errorf("lineCount(%q) = %d; want %d", content, got, want)
+		}
+	}
+}
+
+func TestRunWithMeta(t *testing.T) {
+	tmp := t.TempDir()
+	os.Mkdir(filepath.Join(tmp, "sub"), 0755)
+	old := time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)
+	recent := time.Date(2025, 6, 2, 18, 5, 0, 0, time.Local)
+	files := map[string]struct {
+		content string
+		mtime   time.Time
+	}{
+		"a.txt":     {"one\ntwo\n", old},
+		"sub/b.txt": {strings.Repeat("x\n", 1024), old},
+		"sub/c.txt": {"last", recent},
+	}
+	for rel, f := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(rel))
+		os.WriteFile(path, []byte(f.content), 0644)
+		os.Chmod(path, 0644)
+		os.Chtimes(path, f.mtime, f.mtime)
+	}
+
+	out, err := Run(&Config{RootPath: tmp, ShowTree: true, Meta: "size,lines,mtime,mode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"├── a.txt [8 B, 2 lines, 2024-03-01 09:30, -rw-r--r--]\n",
+		"└── sub [2.0 KB, 1025 lines, 2025-06-02 18:05, d",
+		"│   └── c.txt [4 B, 1 line, 2025-06-02 18:05, -rw-r--r--]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	// Files over MaxFileSize aren't read, so their lines aren't counted
+	out, err = Run(&Config{RootPath: tmp, ShowTree: true, Meta: "lines", MaxFileSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "b.txt [skipped: 2.0 KB]\n") || !strings.Contains(out, "sub [1 line]") {
+		t.Errorf("skipped file shouldn't have a line count:\n%s", out)
+	}
+
+	if _, err := Run(&Config{RootPath: tmp, Meta: "owner"}); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
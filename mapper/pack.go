@@ -0,0 +1,208 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultPriority is the Config.Priority used when it's empty: README files,
+// then entry points and manifests, tests last, smaller files first.
+const DefaultPriority = "readme,entry,tests-last,small"
+
+// priorityRules maps each rule accepted in Config.Priority to a sort key;
+// lower keys are packed first
+var priorityRules = map[string]func(e Entry, tokens int) int{
+	"readme": func(e Entry, _ int) int {
+		return boolKey(!isReadme(e.RelPath))
+	},
+	"entry": func(e Entry, _ int) int {
+		return boolKey(!isEntryPoint(e.RelPath))
+	},
+	"tests-last": func(e Entry, _ int) int {
+		return boolKey(isTestFile(e.RelPath))
+	},
+	"small": func(_ Entry, tokens int) int {
+		return tokens
+	},
+	"shallow": func(e Entry, _ int) int {
+		return strings.Count(e.RelPath, "/")
+	},
+}
+
+func boolKey(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// omittedFile is a file left out to fit Config.MaxTokens
+type omittedFile struct {
+	Entry
+	Tokens int
+	Reason string
+}
+
+// pack drops the files that don't fit cfg.MaxTokens, considering them in
+// priority order, and returns the entries to render; t.omitted lists the
+// rest. measure returns what rendering the given entries costs, so the
+// estimate used for packing can be corrected until the output fits. When
+// even the listing without any file content is over the budget, pack
+// fails rather than write more than asked for.
+func (t *tokenReport) pack(ctx context.Context, cfg *Config, entries []Entry, measure func([]Entry) (int, error)) ([]Entry, error) {
+	order, err := t.prioritize(cfg.Priority, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := cfg.MaxTokens
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		kept := make(map[string]bool)
+		t.omitted = nil
+		used := 0
+		for _, e := range order {
+			n := t.files[e.Path]
+			// Headers, tree lines and markup around the content
+			cost := n + 2*t.counter.Count(e.Path) + 8
+			switch {
+			case n > cfg.MaxTokens:
+				t.omitted = append(t.omitted, omittedFile{e, n, "larger than the whole budget"})
+			case used+cost > limit:
+				t.omitted = append(t.omitted, omittedFile{e, n, "no room left in the budget"})
+			default:
+				kept[e.Path] = true
+				used += cost
+			}
+		}
+		sort.Slice(t.omitted, func(i, j int) bool { return t.omitted[i].RelPath < t.omitted[j].RelPath })
+
+		packed := keepEntries(entries, kept, true)
+		total, err := measure(packed)
+		if err != nil {
+			return nil, err
+		}
+		if total <= cfg.MaxTokens {
+			return packed, nil
+		}
+		if len(kept) == 0 {
+			return nil, fmt.Errorf("the output can't fit in %d tokens: the listing alone takes %d", cfg.MaxTokens, total)
+		}
+
+		// The estimate was too low; shrink the limit by the overshoot, and
+		// faster if that keeps happening
+		limit -= total - cfg.MaxTokens
+		if attempt > 3 {
+			limit -= limit / 10
+		}
+	}
+}
+
+// prioritize returns the file entries sorted by the comma-separated rules,
+// keeping the walk order between files the rules consider equal
+func (t *tokenReport) prioritize(rules string, entries []Entry) ([]Entry, error) {
+	if rules == "" {
+		rules = DefaultPriority
+	}
+	var keys []func(Entry, int) int
+	for _, name := range splitPatterns(rules) {
+		key, ok := priorityRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown priority rule %q", name)
+		}
+		keys = append(keys, key)
+	}
+
+	var files []Entry
+	for _, e := range entries {
+		if !e.IsDir {
+			files = append(files, e)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := key(files[i], t.files[files[i].Path]), key(files[j], t.files[files[j].Path])
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return files, nil
+}
+
+// keepEntries returns the entries whose files are in kept, plus the
+// directories still holding one of them. Directories that were empty to
+// begin with stay if keepEmpty is set. Truncated directories count as
+// holding kept files, since theirs aren't listed.
+func keepEntries(entries []Entry, kept map[string]bool, keepEmpty bool) []Entry {
+	hadFiles := make(map[string]bool)
+	hasKept := make(map[string]bool)
+	for _, e := range entries {
+		if e.IsDir && !e.Truncated {
+			continue
+		}
+		for dir := path.Dir(e.RelPath); dir != "."; dir = path.Dir(dir) {
+			hadFiles[dir] = true
+			if kept[e.Path] || e.Truncated {
+				hasKept[dir] = true
+			}
+		}
+	}
+
+	var result []Entry
+	for _, e := range entries {
+		if e.IsDir && (keepEmpty && !hadFiles[e.RelPath] || hasKept[e.RelPath] || e.Truncated) || !e.IsDir && kept[e.Path] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// isReadme reports whether rel is a README file
+func isReadme(rel string) bool {
+	return strings.HasPrefix(strings.ToLower(path.Base(rel)), "readme")
+}
+
+// entryPointNames are file names (without extension) that usually start a
+// program or describe how to build it
+var entryPointNames = map[string]bool{
+	"main": true, "index": true, "app": true, "server": true, "cli": true,
+	"__main__": true, "manage": true, "setup": true,
+	"go.mod": true, "package.json": true, "cargo.toml": true, "pyproject.toml": true,
+	"pom.xml": true, "build.gradle": true, "makefile": true, "dockerfile": true,
+}
+
+// isEntryPoint reports whether rel looks like an entry point or manifest
+func isEntryPoint(rel string) bool {
+	base := strings.ToLower(path.Base(rel))
+	if entryPointNames[base] || entryPointNames[strings.TrimSuffix(base, path.Ext(base))] {
+		return true
+	}
+	return strings.HasPrefix(rel, "cmd/") || strings.Contains(rel, "/cmd/")
+}
+
+// testDirs are directory names that hold tests or test data
+var testDirs = map[string]bool{
+	"test": true, "tests": true, "__tests__": true, "testdata": true, "spec": true,
+}
+
+// isTestFile reports whether rel looks like a test or test fixture
+func isTestFile(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if testDirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	base := strings.ToLower(parts[len(parts)-1])
+	name := strings.TrimSuffix(base, path.Ext(base))
+	return strings.HasSuffix(name, "_test") || strings.HasPrefix(name, "test_") ||
+		strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec")
+}
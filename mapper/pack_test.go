@@ -0,0 +1,165 @@
+package mapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky93/file-mapper/internal/tokenizer"
+)
+
+func TestPriorityHelpers(t *testing.T) {
+	cases := []struct {
+		rel                   string
+		readme, entry, isTest bool
+	}{
+		{"README.md", true, false, false},
+		{"docs/readme.txt", true, false, false},
+		{"main.go", false, true, false},
+		{"cmd/tool/run.go", false, true, false},
+		{"package.json", false, true, false},
+		{"src/index.ts", false, true, false},
+		{"mapper/print.go", false, false, false},
+		{"mapper/print_test.go", false, false, true},
+		{"tests/helpers.py", false, false, true},
+		{"pkg/testdata/in.txt", false, false, true},
+		{"web/app.spec.ts", false, false, true},
+		{"test_utils.py", false, false, true},
+	}
+	for _, c := range cases {
+		if got := isReadme(c.rel); got != c.readme {
+			t.Errorf("isReadme(%q) = %v; want %v", c.rel, got, c.readme)
+		}
+		if got := isEntryPoint(c.rel); got != c.entry {
+			t.Errorf("isEntryPoint(%q) = %v; want %v", c.rel, got, c.entry)
+		}
+		if got := isTestFile(c.rel); got != c.isTest {
+			t.Errorf("isTestFile(%q) = %v; want %v", c.rel, got, c.isTest)
+		}
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	entries := []Entry{
+		{Path: "a_test.go", RelPath: "a_test.go"},
+		{Path: "big.go", RelPath: "big.go"},
+		{Path: "dir", RelPath: "dir", IsDir: true},
+		{Path: "dir/small.go", RelPath: "dir/small.go"},
+		{Path: "main.go", RelPath: "main.go"},
+		{Path: "README.md", RelPath: "README.md"},
+	}
+	report := &tokenReport{files: map[string]int{
+		"a_test.go": 1, "big.go": 500, "dir/small.go": 10, "main.go": 300, "README.md": 900,
+	}}
+
+	cases := []struct {
+		rules string
+		want  string
+	}{
+		{"", "README.md main.go dir/small.go big.go a_test.go"},
+		{"small", "a_test.go dir/small.go main.go big.go README.md"},
+		{"tests-last,shallow", "big.go main.go README.md dir/small.go a_test.go"},
+	}
+	for _, c := range cases {
+		files, err := report.prioritize(c.rules, entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range files {
+			got = append(got, e.RelPath)
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("prioritize(%q) = %v; want %s", c.rules, got, c.want)
+		}
+	}
+
+	if _, err := report.prioritize("readme,biggest", entries); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+}
+
+func TestKeepEntries(t *testing.T) {
+	entries := []Entry{
+		{Path: "a", RelPath: "a", IsDir: true},
+		{Path: "a/x.go", RelPath: "a/x.go"},
+		{Path: "b", RelPath: "b", IsDir: true},
+		{Path: "b/y.go", RelPath: "b/y.go"},
+		{Path: "empty", RelPath: "empty", IsDir: true},
+	}
+	var got []string
+	for _, e := range keepEntries(entries, map[string]bool{"a/x.go": true}, true) {
+		got = append(got, e.RelPath)
+	}
+	if want := "a a/x.go empty"; strings.Join(got, " ") != want {
+		t.Errorf("keepEntries = %v; want %s", got, want)
+	}
+}
+
+func TestRunWithMaxTokens(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "README.md"), []byte("# Project\n\nShort intro.\n"), 0644)
+	os.WriteFile(filepath.Join(tmp, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tmp, "huge.txt"), []byte(strings.Repeat("lorem ipsum dolor sit amet ", 200)), 0644)
+	os.WriteFile(filepath.Join(tmp, "main_test.go"), []byte(strings.Repeat("// filler comment line\n", 30)), 0644)
+
+	cfg := &Config{
+		RootPath:          tmp,
+		ShowTree:          true,
+		ShowContent:       true,
+		SeparateContent:   true,
+		ShowHeaderFooters: true,
+		MaxTokens:         150,
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, _ := tokenizer.New(TokenizerCL100K)
+	if n := counter.Count(out); n > cfg.MaxTokens {
+		t.Errorf("output is %d tokens; budget is %d:\n%s", n, cfg.MaxTokens, out)
+	}
+	for _, want := range []string{
+		"README.md (4 lines):",
+		"main.go (4 lines):",
+		"Omitted to fit 150 tokens:\n",
+		"huge.txt (1002 tokens): larger than the whole budget\n",
+		"main_test.go (150 tokens): no room left in the budget\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "lorem") {
+		t.Errorf("omitted content was printed:\n%s", out)
+	}
+
+	// JSON metadata is heavier; the budget leaves room for README only
+	cfg.MaxTokens = 300
+	cfg.Format = FormatJSON
+	if out, err = Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Omitted []jsonOmitted `json:"omitted"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	var omitted []string
+	for _, o := range doc.Omitted {
+		omitted = append(omitted, o.Path)
+	}
+	if strings.Join(omitted, " ") != "huge.txt main.go main_test.go" {
+		t.Errorf("omitted = %v", omitted)
+	}
+
+	// A budget the listing alone doesn't fit in is an error, not a longer dump
+	cfg.MaxTokens = 20
+	if out, err = Run(cfg); err == nil || !strings.Contains(err.Error(), "can't fit in 20 tokens") {
+		t.Errorf("expected an error for a budget below the listing, got %v:\n%s", err, out)
+	}
+}
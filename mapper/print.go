@@ -0,0 +1,372 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// writeText renders the default human-readable format: a tree or flat list,
+// with content either inline or in a separate section afterward. With
+// cfg.ShowTokens files are annotated with token counts and a total follows.
+func writeText(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry) error {
+	return writeWithTokens(ctx, w, cfg, entries, writeTextBody, writeTextFooter)
+}
+
+// writeTextBody writes the listing and content, then the files omitted to
+// fit cfg.MaxTokens
+func writeTextBody(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error {
+	if err := writeTextListing(ctx, w, cfg, entries, tokens); err != nil {
+		return err
+	}
+
+	// The separate content section already ends with a blank line
+	blank := cfg.ShowContent && cfg.SeparateContent && len(fileEntries(entries)) > 0
+	if tokens != nil && len(tokens.omitted) > 0 {
+		if !blank {
+			w.WriteString("\n")
+		}
+		fmt.Fprintf(w, "Omitted to fit %d tokens:\n", cfg.MaxTokens)
+		for _, o := range tokens.omitted {
+			fmt.Fprintf(w, "  %s (%d tokens): %s\n", o.Path, o.Tokens, o.Reason)
+		}
+		blank = false
+	}
+	if tokens.shown() && !blank {
+		w.WriteString("\n")
+	}
+	return nil
+}
+
+// writeTextFooter writes the token total
+func writeTextFooter(w *bufio.Writer, cfg *Config, tokens *tokenReport, total int) {
+	if tokens.shown() {
+		fmt.Fprintf(w, "Total: %d tokens (%d files)\n", total, tokens.keptFiles())
+	}
+}
+
+// writeTextListing writes the tree or flat list with its content
+func writeTextListing(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error {
+	if cfg.ShowTree {
+		// Write the tree; with cfg.ShowContent && !cfg.SeparateContent
+		// the content is inlined under each file
+		fileOrder, err := writeTree(ctx, w, cfg, cfg.RootPath, entries, tokens)
+		if err != nil {
+			return err
+		}
+
+		// Optionally print file contents separately after the tree
+		if cfg.ShowContent && cfg.SeparateContent && len(fileOrder) > 0 {
+			w.WriteString("\n")
+			return writeSeparateContentSection(ctx, w, fileOrder, cfg, tokens)
+		}
+		return nil
+	}
+
+	// We want content inlined with the flat listing
+	if cfg.ShowContent && !cfg.SeparateContent {
+		return writeFlatListWithContent(ctx, w, entries, cfg, tokens)
+	}
+
+	// If no content or separate content, just print the file listing
+	writeFlatList(w, cfg, entries, tokens)
+	if files := fileEntries(entries); cfg.ShowContent && len(files) > 0 {
+		w.WriteString("\n")
+		return writeSeparateContentSection(ctx, w, files, cfg, tokens)
+	}
+	return nil
+}
+
+// writeTree writes a tree-like view of the entries, siblings sorted by
+// cfg.Sort, and returns the files in the order they appeared. If cfg.ShowContent && !cfg.SeparateContent,
+// it will inline the content under each file in the tree itself. Files
+// counted in tokens get their token count after the name.
+func writeTree(ctx context.Context, w *bufio.Writer, cfg *Config, root string, entries []Entry, tokens *tokenReport) ([]Entry, error) {
+	// Build map of dir -> children, in cfg's sort order
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sortEntries(cfg, sorted)
+	treeMap := make(map[string][]Entry)
+	for _, e := range sorted {
+		dir := filepath.Dir(filepath.FromSlash(e.RelPath))
+		treeMap[dir] = append(treeMap[dir], e)
+	}
+
+	var fileOrder []Entry
+
+	// We'll recurse from top-level (".")
+	err := recurseTree(ctx, w, cfg, root, ".", treeMap, 0, &fileOrder, entryLabels(cfg, entries), tokens)
+	return fileOrder, err
+}
+
+// recurseTree is a recursive helper to print directories/files in a tree view.
+func recurseTree(
+	ctx context.Context,
+	w *bufio.Writer,
+	cfg *Config,
+	root string,
+	dir string,
+	treeMap map[string][]Entry,
+	level int,
+	fileOrder *[]Entry,
+	labels map[string]string,
+	tokens *tokenReport,
+) error {
+	children, ok := treeMap[dir]
+	if !ok {
+		return nil
+	}
+
+	for i, e := range children {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		indent(w, level)
+
+		connector := "├──"
+		if i == len(children)-1 {
+			connector = "└──"
+		}
+
+		child := filepath.FromSlash(e.RelPath)
+		base := filepath.Base(child)
+		fullPath := filepath.Join(root, child)
+		fmt.Fprintf(w, "%s %s%s%s\n", connector, base, labels[fullPath], tokens.label(fullPath))
+
+		// Is child a directory with further children?
+		if hasChildren(treeMap, child) {
+			// Recurse deeper
+			if err := recurseTree(ctx, w, cfg, root, child, treeMap, level+1, fileOrder, labels, tokens); err != nil {
+				return err
+			}
+		} else {
+			// It's a file
+			*fileOrder = append(*fileOrder, e)
+
+			// If we should show content inline (tree + content, but NOT separate)
+			if cfg.ShowContent && !cfg.SeparateContent {
+				if err := writeInlineContent(w, cfg, e, level+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeInlineContent streams the content of a single file inline,
+// under the current tree level. We handle line-numbers and header-footers here.
+func writeInlineContent(w *bufio.Writer, cfg *Config, e Entry, level int) error {
+	r, err := openContent(cfg, e)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	prefix := strings.Repeat(indentUnit, level)
+
+	lr, _ := e.run.lines(e.Path)
+	if marker := continuedMarker("from", lr.before); marker != "" {
+		w.WriteString(prefix + marker)
+	}
+	if cfg.ShowHeaderFooters {
+		// Indent a line, print "----- CONTENT START -----"
+		w.WriteString(prefix + "----- CONTENT START -----\n")
+	}
+
+	// Print each line with indentation, and line numbers if requested
+	if err := writeLines(w, cfg, r, prefix, lr.from+1); err != nil {
+		return err
+	}
+
+	if cfg.ShowHeaderFooters {
+		w.WriteString(prefix + "----- CONTENT END -----\n")
+	}
+	if marker := continuedMarker("in", lr.after); marker != "" {
+		w.WriteString(prefix + marker)
+	}
+	writeDiffBlock(w, cfg, e, prefix)
+	return nil
+}
+
+// hasChildren checks if there are sub-entries for the given key
+func hasChildren(treeMap map[string][]Entry, key string) bool {
+	_, ok := treeMap[key]
+	return ok
+}
+
+// writeFlatList writes a simple list of all entries (dirs + files)
+func writeFlatList(w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) {
+	labels := entryLabels(cfg, entries)
+	for _, e := range entries {
+		w.WriteString(e.Path + labels[e.Path] + tokens.label(e.Path) + "\n")
+	}
+}
+
+// writeFlatListWithContent inlines file content after each file path
+func writeFlatListWithContent(ctx context.Context, w *bufio.Writer, entries []Entry, cfg *Config, tokens *tokenReport) error {
+	labels := entryLabels(cfg, entries)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Just print directories
+		w.WriteString(e.Path + labels[e.Path] + tokens.label(e.Path) + "\n")
+		if e.IsDir {
+			continue
+		}
+
+		// It's a file
+		r, err := openContent(cfg, e)
+		if err != nil {
+			continue
+		}
+		err = writeContentBlock(w, cfg, e, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		writeDiffBlock(w, cfg, e, "")
+	}
+	return nil
+}
+
+// writeSeparateContentSection prints content for each file in order
+// e.g. "mapper/listing.go (60 lines):", or "(60 lines, 412 tokens)" when
+// tokens are counted, followed by the last commit with cfg.GitLog
+func writeSeparateContentSection(ctx context.Context, w *bufio.Writer, files []Entry, cfg *Config, tokens *tokenReport) error {
+	for _, e := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := e.Path
+		info, err := statFile(e.run, path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// The line count has to be known before the content is streamed
+		r, stats, err := openContentStats(cfg, e)
+		if err != nil {
+			continue
+		}
+
+		// "filename (NN lines):", plus the last commit with cfg.GitLog
+		commit := ""
+		if summary := commitSummary(e.LastCommit); summary != "" {
+			commit = " [" + summary + "]"
+		}
+		switch n, ok := tokens.count(path); {
+		case e.Skipped:
+			// Only the skipped marker is shown, so there's nothing to count
+			fmt.Fprintf(w, "%s%s:\n", path, commit)
+		case ok:
+			fmt.Fprintf(w, "%s (%s, %d tokens)%s:\n", path, lineSummary(stats), n, commit)
+		default:
+			fmt.Fprintf(w, "%s (%s)%s:\n", path, lineSummary(stats), commit)
+		}
+
+		err = writeContentBlock(w, cfg, e, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		writeDiffBlock(w, cfg, e, "")
+		w.WriteString("\n")
+	}
+	return nil
+}
+
+// lineSummary describes the line count of a file in its header, e.g.
+// "60 lines", or "900 lines, truncated" when the line limits cut it
+func lineSummary(stats *contentStats) string {
+	if stats.Total > 0 {
+		return fmt.Sprintf("%d lines, truncated", stats.Total)
+	}
+	return fmt.Sprintf("%d lines", stats.Lines)
+}
+
+// writeContentBlock streams the content of e between the optional
+// header/footer markers, and the "continued" markers of a file spread over
+// several RunSplit parts
+func writeContentBlock(w *bufio.Writer, cfg *Config, e Entry, r io.Reader) error {
+	lr, _ := e.run.lines(e.Path)
+	w.WriteString(continuedMarker("from", lr.before))
+	if cfg.ShowHeaderFooters {
+		w.WriteString("----- CONTENT START -----\n")
+	}
+
+	var err error
+	if cfg.ShowLineNumbers {
+		err = writeLines(w, cfg, r, "", lr.from+1)
+	} else {
+		// Copied verbatim, with a trailing newline ensured
+		err = copyContent(w, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.ShowHeaderFooters {
+		w.WriteString("----- CONTENT END -----\n")
+	}
+	w.WriteString(continuedMarker("in", lr.after))
+	return nil
+}
+
+// writeDiffBlock writes a changed file's diff (see Config.ShowDiff) after its
+// content, with prefix before every line
+func writeDiffBlock(w *bufio.Writer, cfg *Config, e Entry, prefix string) {
+	diff := fileDiff(cfg, e)
+	if diff == nil {
+		return
+	}
+	if cfg.ShowHeaderFooters {
+		w.WriteString(prefix + "----- DIFF START -----\n")
+	}
+	for _, line := range strings.SplitAfter(string(diff), "\n") {
+		if line != "" {
+			w.WriteString(prefix + line)
+		}
+	}
+	if cfg.ShowHeaderFooters {
+		w.WriteString(prefix + "----- DIFF END -----\n")
+	}
+}
+
+// buildPlainListing returns the tree (or flat list) without any inline content,
+// along with the files in the order they appear in it. Formats that print
+// content in their own markup build on this; the listing itself is small
+// enough to keep in memory.
+func buildPlainListing(cfg *Config, entries []Entry, tokens *tokenReport) (string, []Entry) {
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+
+	if !cfg.ShowTree {
+		writeFlatList(w, cfg, entries, tokens)
+		w.Flush()
+		return sb.String(), fileEntries(entries)
+	}
+
+	treeCfg := *cfg
+	treeCfg.ShowContent = false
+	fileOrder, _ := writeTree(context.Background(), w, &treeCfg, cfg.RootPath, entries, tokens)
+	w.Flush()
+	return sb.String(), fileOrder
+}
+
+// indentUnit is one level of tree indentation
+const indentUnit = "│   "
+
+// indent writes indentation for the tree
+func indent(w *bufio.Writer, level int) {
+	for i := 0; i < level; i++ {
+		w.WriteString(indentUnit)
+	}
+}
@@ -1,13 +1,47 @@
-package listing
+package mapper
 
 import (
+	"bufio"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 )
 
-func TestBuildTreeOutput(t *testing.T) {
+// entriesFor stats the given paths under root and turns them into entries
+func entriesFor(t *testing.T, root string, paths ...string) []Entry {
+	t.Helper()
+	var entries []Entry
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, Entry{Path: p, RelPath: filepath.ToSlash(rel), IsDir: info.IsDir(), Info: info})
+	}
+	return entries
+}
+
+// render runs a writer-based renderer and returns everything it wrote
+func render(t *testing.T, fn func(w *bufio.Writer) error) string {
+	t.Helper()
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	if err := fn(w); err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestWriteTree(t *testing.T) {
 	tmp := t.TempDir()
 	sub := filepath.Join(tmp, "dir")
 	os.MkdirAll(sub, 0755)
@@ -18,7 +52,7 @@ func TestBuildTreeOutput(t *testing.T) {
 	os.WriteFile(file2, []byte("file2 content"), 0644)
 
 	// We'll emulate a small slice of accepted paths
-	entries := []string{file1, sub, file2}
+	entries := entriesFor(t, tmp, file1, sub, file2)
 
 	cfg := &Config{
 		RootPath:          tmp,
@@ -29,24 +63,31 @@ func TestBuildTreeOutput(t *testing.T) {
 		ShowHeaderFooters: true,
 	}
 
-	treeOut := buildTreeOutput(cfg, tmp, entries)
-	if !strings.Contains(treeOut.TreeString, "file1.txt") {
+	var fileOrder []Entry
+	treeOut := render(t, func(w *bufio.Writer) (err error) {
+		fileOrder, err = writeTree(context.Background(), w, cfg, tmp, entries, nil)
+		return err
+	})
+	if !strings.Contains(treeOut, "file1.txt") {
 		t.Error("Expected file1.txt in tree output")
 	}
-	if !strings.Contains(treeOut.TreeString, "file2.md") {
+	if !strings.Contains(treeOut, "file2.md") {
 		t.Error("Expected file2.md in tree output")
 	}
-	if !strings.Contains(treeOut.TreeString, "----- CONTENT START -----") {
+	if !strings.Contains(treeOut, "----- CONTENT START -----") {
 		t.Error("Expected inline content markers in tree output")
 	}
-	if len(treeOut.FileOrder) != 2 {
-		t.Errorf("Expected 2 files in FileOrder, got %d", len(treeOut.FileOrder))
+	if len(fileOrder) != 2 {
+		t.Errorf("Expected 2 files in fileOrder, got %d", len(fileOrder))
 	}
 }
 
-func TestBuildFlatListOutput(t *testing.T) {
-	entries := []string{"file1.txt", "dir", "file2.md"}
-	out := buildFlatListOutput(entries)
+func TestWriteFlatList(t *testing.T) {
+	entries := []Entry{{Path: "file1.txt"}, {Path: "dir", IsDir: true}, {Path: "file2.md"}}
+	out := render(t, func(w *bufio.Writer) error {
+		writeFlatList(w, &Config{}, entries, nil)
+		return nil
+	})
 	if !strings.Contains(out, "file1.txt") {
 		t.Error("Expected file1.txt in flat output")
 	}
@@ -58,20 +99,22 @@ func TestBuildFlatListOutput(t *testing.T) {
 	}
 }
 
-func TestBuildFlatListWithContent(t *testing.T) {
+func TestWriteFlatListWithContent(t *testing.T) {
 	tmp := t.TempDir()
 	file1 := filepath.Join(tmp, "file1.txt")
 	os.WriteFile(file1, []byte("hello"), 0644)
 	dir1 := filepath.Join(tmp, "sub")
 	os.MkdirAll(dir1, 0755)
 
-	entries := []string{file1, dir1}
+	entries := entriesFor(t, tmp, file1, dir1)
 	cfg := &Config{
 		ShowLineNumbers:   false,
 		ShowHeaderFooters: true,
 	}
 
-	out := buildFlatListWithContent(entries, cfg)
+	out := render(t, func(w *bufio.Writer) error {
+		return writeFlatListWithContent(context.Background(), w, entries, cfg, nil)
+	})
 	if !strings.Contains(out, "file1.txt") {
 		t.Error("Expected file1.txt in output")
 	}
@@ -87,7 +130,7 @@ func TestBuildFlatListWithContent(t *testing.T) {
 	}
 }
 
-func TestBuildSeparateContentSection(t *testing.T) {
+func TestWriteSeparateContentSection(t *testing.T) {
 	tmp := t.TempDir()
 	file1 := filepath.Join(tmp, "file1.txt")
 	os.WriteFile(file1, []byte("line1\nline2"), 0644)
@@ -99,7 +142,9 @@ func TestBuildSeparateContentSection(t *testing.T) {
 		ShowHeaderFooters: true,
 	}
 
-	out := buildSeparateContentSection([]string{file1, file2}, cfg)
+	out := render(t, func(w *bufio.Writer) error {
+		return writeSeparateContentSection(context.Background(), w, entriesFor(t, tmp, file1, file2), cfg, nil)
+	})
 	if !strings.Contains(out, "line1") || !strings.Contains(out, "line2") {
 		t.Error("Expected file1 lines in separate content")
 	}
@@ -0,0 +1,124 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+)
+
+// Renderer turns the entries found by Walk into output. Implementations
+// should stream to w rather than buffer, and stop when ctx is cancelled.
+type Renderer interface {
+	Render(ctx context.Context, w io.Writer, cfg *Config, entries []Entry) error
+}
+
+// RendererFunc adapts an ordinary function to the Renderer interface
+type RendererFunc func(ctx context.Context, w io.Writer, cfg *Config, entries []Entry) error
+
+// Render calls f(ctx, w, cfg, entries)
+func (f RendererFunc) Render(ctx context.Context, w io.Writer, cfg *Config, entries []Entry) error {
+	return f(ctx, w, cfg, entries)
+}
+
+// NewRenderer returns the built-in renderer for one of the Format* constants.
+// An empty format selects FormatText.
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case "", FormatText:
+		return bufferedRenderer(writeText), nil
+	case FormatJSON:
+		return bufferedRenderer(writeJSON), nil
+	case FormatMarkdown:
+		return bufferedRenderer(writeMarkdown), nil
+	case FormatXML:
+		return bufferedRenderer(writeXML), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// chooseRenderer returns cfg.Renderer, or the built-in one for cfg.Format
+func chooseRenderer(cfg *Config) (Renderer, error) {
+	if cfg.Renderer != nil {
+		return cfg.Renderer, nil
+	}
+	return NewRenderer(cfg.Format)
+}
+
+// bufferedRenderer wraps one of the built-in writers. They ignore individual
+// write errors: bufio.Writer keeps the first one and Flush reports it.
+type bufferedRenderer func(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry) error
+
+// Render implements Renderer
+func (r bufferedRenderer) Render(ctx context.Context, w io.Writer, cfg *Config, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	if err := r(ctx, bw, cfg, entries); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// filePaths returns the paths of the file entries, in order
+func filePaths(entries []Entry) []string {
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// fileEntries returns the file entries, in order
+func fileEntries(entries []Entry) []Entry {
+	var files []Entry
+	for _, e := range entries {
+		if !e.IsDir {
+			files = append(files, e)
+		}
+	}
+	return files
+}
+
+// entriesRun returns the Walk run the entries come from, or nil if they
+// weren't found by Walk
+func entriesRun(entries []Entry) *walkRun {
+	for _, e := range entries {
+		if e.run != nil {
+			return e.run
+		}
+	}
+	return nil
+}
+
+// entryLabels returns what listings show right after an entry's name, by
+// Entry.Path: "/ (412 files, not expanded)" for truncated directories,
+// " [submodule]" for nested repositories, " [skipped: 2.4 MB]" for files over
+// cfg.MaxFileSize, the git status, e.g. " [M]", the
+// cfg.Meta columns, e.g. " [4.2 KB, 120 lines]",
+// and the last commit, e.g. " [a1b2c3d, Jane Doe, 3 days ago: Fix the parser]"
+func entryLabels(cfg *Config, entries []Entry) map[string]string {
+	labels := metaLabels(cfg, entries)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for _, e := range entries {
+		if commit := commitSummary(e.LastCommit); commit != "" {
+			labels[e.Path] += " [" + commit + "]"
+		}
+		if e.GitStatus != "" {
+			labels[e.Path] = " [" + e.GitStatus + "]" + labels[e.Path]
+		}
+		if e.Skipped {
+			labels[e.Path] = " [skipped: " + formatSize(e.Info.Size()) + "]" + labels[e.Path]
+		}
+		if e.NestedRepo != "" {
+			labels[e.Path] = " [" + e.NestedRepo + "]" + labels[e.Path]
+		}
+		if e.Truncated {
+			labels[e.Path] = "/ (" + plural(e.Files, "file") + ", not expanded)" + labels[e.Path]
+		}
+	}
+	return labels
+}
@@ -0,0 +1,42 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewRenderer(t *testing.T) {
+	for _, format := range []string{"", FormatText, FormatJSON, FormatMarkdown, FormatXML} {
+		if r, err := NewRenderer(format); err != nil || r == nil {
+			t.Errorf("NewRenderer(%q) = %v, %v", format, r, err)
+		}
+	}
+	if _, err := NewRenderer("yaml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestCustomRenderer(t *testing.T) {
+	errStop := errors.New("stop")
+	var got []Entry
+	cfg := &Config{
+		RootPath: t.TempDir(),
+		Format:   "ignored-when-a-renderer-is-set",
+		Renderer: RendererFunc(func(ctx context.Context, w io.Writer, cfg *Config, entries []Entry) error {
+			got = entries
+			io.WriteString(w, "custom")
+			return errStop
+		}),
+	}
+
+	var sb strings.Builder
+	if err := RunTo(context.Background(), cfg, &sb); err != errStop {
+		t.Errorf("Expected the renderer's error, got %v", err)
+	}
+	if sb.String() != "custom" || got != nil {
+		t.Errorf("Unexpected custom render: %q, %+v", sb.String(), got)
+	}
+}
@@ -0,0 +1,314 @@
+package mapper
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Secrets modes accepted by Config.Secrets (empty means SecretsRedact, as
+// in the CLI)
+const (
+	SecretsOff      = "off"
+	SecretsRedact   = "redact"    // replace each secret with [REDACTED:<kind>]
+	SecretsSkipFile = "skip-file" // leave files containing secrets out
+	SecretsFail     = "fail"      // stop before writing anything
+)
+
+// SecretFinding is a credential found in a file's content. The content is
+// scanned as it's shown, after Config.Skeleton, Config.StripComments and the
+// like rewrote it, so with those Line may differ from the file on disk.
+type SecretFinding struct {
+	Path string // as in Entry.Path
+	Line int    // 1-based line where the secret starts, in the content as shown
+	Kind string // e.g. "aws-access-key", "private-key", "password"
+}
+
+// secretRule detects one kind of secret. The regexp only runs on lines
+// containing one of hints (or, for multiline rules, on whole files
+// containing one), which keeps scanning fast. Only the given capture group
+// is redacted (0 for the whole match); check, if set, must accept it too.
+type secretRule struct {
+	kind      string
+	hints     []string // lowercase
+	re        *regexp.Regexp
+	group     int
+	check     func(value []byte, quoted, config bool) bool
+	multiline bool
+}
+
+var secretRules = []secretRule{
+	{
+		kind:      "private-key",
+		hints:     []string{"private key"},
+		re:        regexp.MustCompile(`-----BEGIN[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----`),
+		multiline: true,
+	},
+	{
+		kind:  "aws-access-key",
+		hints: []string{"akia", "asia", "abia", "acca"},
+		re:    regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		kind:  "aws-secret-key",
+		hints: []string{"aws"},
+		re:    regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|private).{0,20}?['"]?\s*(?::=|=>|[:=])\s*['"]?([A-Za-z0-9/+=]{40})\b`),
+		group: 1,
+	},
+	{
+		kind:  "github-token",
+		hints: []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"},
+		re:    regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	},
+	{
+		kind:  "slack-token",
+		hints: []string{"xox"},
+		re:    regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	},
+	{
+		kind:  "slack-webhook",
+		hints: []string{"hooks.slack.com"},
+		re:    regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9/]+`),
+	},
+	{
+		kind:  "jwt",
+		hints: []string{"eyj"},
+		re:    regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	},
+	{
+		kind:  "password",
+		hints: []string{"passw", "pwd"},
+		re:    assignmentRegex(`passw(?:or)?d|pwd`),
+		group: 2,
+		check: looksRandom,
+	},
+	{
+		kind:  "secret",
+		hints: []string{"secret", "token", "key", "credential"},
+		re:    assignmentRegex(`secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?`),
+		group: 2,
+		check: looksRandom,
+	},
+}
+
+// assignmentRegex matches a value assigned to a key whose name contains one
+// of keys, as in code, JSON, YAML or .env files. Group 1 is the opening
+// quote, group 2 the value.
+func assignmentRegex(keys string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:` + keys + `)[\w.-]*["']?\s*(?::=|=>|[:=])\s*(["'` + "`" + `]?)([^\s"'` + "`" + `,;()]{8,})`)
+}
+
+// placeholderValues are substrings of values that are clearly not secrets
+var placeholderValues = []string{"${", "{{", "<", "xxxx", "changeme", "example", "dummy", "redacted", "placeholder", "your"}
+
+// dottedIdentifier matches references like cfg.Password or pkg.Token2
+var dottedIdentifier = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)+$`)
+
+// looksRandom accepts assigned values that look like real credentials:
+// high entropy, not a placeholder, and holding a digit or a symbol (plain
+// words and header names are usually not secrets). Unquoted values only
+// count in config files, where they must also mix letters and digits and
+// not be a dotted identifier; in code they're expressions.
+func looksRandom(value []byte, quoted, config bool) bool {
+	lower := strings.ToLower(string(value))
+	for _, p := range placeholderValues {
+		if strings.Contains(lower, p) {
+			return false
+		}
+	}
+	if bytes.IndexFunc(value, isDigitOrSymbol) < 0 {
+		return false
+	}
+	if !quoted && (!config || !bytes.ContainsAny(value, "0123456789") || bytes.IndexFunc(value, isASCIILetter) < 0 || dottedIdentifier.Match(value)) {
+		return false
+	}
+	return shannonEntropy(value) >= 3.0
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isDigitOrSymbol(r rune) bool {
+	return !isASCIILetter(r) && !strings.ContainsRune("-_./", r)
+}
+
+// configExts are extensions of files holding plain key/value settings
+var configExts = map[string]bool{
+	"": true, ".env": true, ".ini": true, ".cfg": true, ".conf": true, ".properties": true,
+	".yaml": true, ".yml": true, ".toml": true, ".txt": true,
+}
+
+// isConfigFile reports whether path holds settings rather than code
+func isConfigFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	return configExts[filepath.Ext(base)] || strings.HasPrefix(base, ".env") || strings.HasSuffix(base, ".env")
+}
+
+// shannonEntropy returns the entropy of b in bits per byte
+func shannonEntropy(b []byte) float64 {
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(b))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// secretMatch is the byte range of a secret in some content
+type secretMatch struct {
+	start, end int
+	kind       string
+}
+
+// findSecrets returns the secrets in a file's content, in order and without
+// overlaps
+func findSecrets(path string, content []byte) []secretMatch {
+	config := isConfigFile(path)
+	lower := asciiLower(content)
+	var matches []secretMatch
+	for _, rule := range secretRules {
+		if !containsAny(lower, rule.hints) {
+			continue
+		}
+		if rule.multiline {
+			matches = rule.find(content, 0, config, matches)
+			continue
+		}
+		for start := 0; start < len(content); {
+			end := bytes.IndexByte(content[start:], '\n')
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += start
+			}
+			if containsAny(lower[start:end], rule.hints) {
+				matches = rule.find(content[start:end], start, config, matches)
+			}
+			start = end + 1
+		}
+	}
+
+	// Earlier rules are more specific, so they win ties
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var result []secretMatch
+	for _, m := range matches {
+		if len(result) == 0 || m.start >= result[len(result)-1].end {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// find appends the rule's matches in text, which starts at offset in the
+// file, to matches
+func (rule *secretRule) find(text []byte, offset int, config bool, matches []secretMatch) []secretMatch {
+	for _, loc := range rule.re.FindAllSubmatchIndex(text, -1) {
+		start, end := loc[2*rule.group], loc[2*rule.group+1]
+		if start < 0 {
+			continue
+		}
+		if rule.check != nil && !rule.check(text[start:end], loc[2] < loc[3], config) {
+			continue
+		}
+		matches = append(matches, secretMatch{offset + start, offset + end, rule.kind})
+	}
+	return matches
+}
+
+// asciiLower lowercases ASCII letters only, so offsets stay the same
+func asciiLower(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
+
+func containsAny(b []byte, substrs []string) bool {
+	for _, s := range substrs {
+		if bytes.Contains(b, []byte(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets replaces each match in content with [REDACTED:<kind>]
+func redactSecrets(content []byte, matches []secretMatch) []byte {
+	if len(matches) == 0 {
+		return content
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		buf.Write(content[last:m.start])
+		fmt.Fprintf(&buf, "[REDACTED:%s]", m.kind)
+		last = m.end
+	}
+	buf.Write(content[last:])
+	return buf.Bytes()
+}
+
+// secretFindings turns the matches in a file into findings with line numbers
+func secretFindings(path string, content []byte, matches []secretMatch) []SecretFinding {
+	var findings []SecretFinding
+	for _, m := range matches {
+		line := bytes.Count(content[:m.start], []byte("\n")) + 1
+		findings = append(findings, SecretFinding{Path: path, Line: line, Kind: m.kind})
+	}
+	return findings
+}
+
+// secretsMode returns cfg.Secrets with the empty default resolved
+func secretsMode(cfg *Config) string {
+	if cfg.Secrets == "" {
+		return SecretsRedact
+	}
+	return cfg.Secrets
+}
+
+// checkSecrets scans a file, and the lines its Config.ShowDiff diff removes,
+// for secrets and reports each to cfg.OnSecret. It returns the secrets in the
+// content, which redaction reuses, and whether any were found. In
+// SecretsFail mode finding one is an error.
+func checkSecrets(cfg *Config, e Entry, mode string) ([]secretMatch, bool, error) {
+	path := e.Path
+	content, err := readFile(e.run, path)
+	if err != nil {
+		// Unreadable files are left to the renderers to skip
+		return nil, false, nil
+	}
+	content = transformContent(cfg, e, content)
+	matches := findSecrets(path, content)
+	findings := secretFindings(path, content, matches)
+	if diff := rawDiff(cfg, e); diff != nil {
+		findings = append(findings, diffFindings(path, diff)...)
+	}
+	if len(findings) == 0 {
+		return nil, false, nil
+	}
+
+	if cfg.OnSecret != nil {
+		for _, f := range findings {
+			cfg.OnSecret(f)
+		}
+	}
+	if mode == SecretsFail {
+		return nil, true, fmt.Errorf("%s:%d: found a secret (%s)", path, findings[0].Line, findings[0].Kind)
+	}
+	return matches, true, nil
+}
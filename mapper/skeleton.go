@@ -0,0 +1,58 @@
+package mapper
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// hasSkeleton reports whether Config.Skeleton applies to path
+func hasSkeleton(cfg *Config, path string) bool {
+	return cfg.Skeleton && strings.EqualFold(filepath.Ext(path), ".go")
+}
+
+// goSkeleton returns the API surface of a Go source file: the package
+// clause, imports, constants, variables, types, function and method
+// signatures and their doc comments, without function bodies
+func goSkeleton(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodies []*ast.BlockStmt
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			bodies = append(bodies, fn.Body)
+			fn.Body = nil
+		}
+	}
+
+	// Comments inside the dropped bodies would otherwise be printed
+	// between the declarations
+	comments := file.Comments[:0]
+	for _, c := range file.Comments {
+		inBody := false
+		for _, body := range bodies {
+			if c.Pos() >= body.Lbrace && c.End() <= body.Rbrace {
+				inBody = true
+				break
+			}
+		}
+		if !inBody {
+			comments = append(comments, c)
+		}
+	}
+	file.Comments = comments
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
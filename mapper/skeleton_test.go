@@ -0,0 +1,90 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoSkeleton(t *testing.T) {
+	src := `// Package demo does things.
+package demo
+
+import "fmt"
+
+// Limit caps things
+const Limit = 3
+
+// Thing is a thing
+type Thing struct {
+	Name string // shown
+}
+
+// String formats a Thing
+func (t Thing) String() string {
+	// body comment
+	return fmt.Sprintf("thing %s", t.Name)
+}
+
+func helper(n int) (int, error) {
+	return n, nil
+}
+`
+	want := `// Package demo does things.
+package demo
+
+import "fmt"
+
+// Limit caps things
+const Limit = 3
+
+// Thing is a thing
+type Thing struct {
+	Name string // shown
+}
+
+// String formats a Thing
+func (t Thing) String() string
+
+func helper(n int) (int, error)
+`
+	got, err := goSkeleton([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("goSkeleton =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := goSkeleton([]byte("package broken\nfunc {")); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestRunWithSkeleton(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"), 0644)
+	os.WriteFile(filepath.Join(tmp, "broken.go"), []byte("package main\nfunc {\n"), 0644)
+	os.WriteFile(filepath.Join(tmp, "notes.txt"), []byte("func main() {}\n"), 0644)
+
+	out, err := Run(&Config{
+		RootPath:        tmp,
+		ShowContent:     true,
+		SeparateContent: true,
+		Skeleton:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "println") || !strings.Contains(out, "func main()\n") {
+		t.Errorf("body not dropped:\n%s", out)
+	}
+	// Files that don't parse and other languages are shown in full
+	if !strings.Contains(out, "func {\n") || !strings.Contains(out, "func main() {}\n") {
+		t.Errorf("expected unparsable and non-Go files in full:\n%s", out)
+	}
+	if !strings.Contains(out, "main.go (4 lines)") {
+		t.Errorf("line count should describe the skeleton:\n%s", out)
+	}
+}
@@ -0,0 +1,143 @@
+package mapper
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Sort orders accepted by Config.Sort (empty means SortName)
+const (
+	SortName  = "name"
+	SortSize  = "size"  // largest first
+	SortMtime = "mtime" // newest first
+	SortLines = "lines" // longest first
+	SortExt   = "ext"   // by extension, then name
+)
+
+// sortKey returns cfg.Sort with the empty default resolved
+func sortKey(cfg *Config) (string, error) {
+	switch cfg.Sort {
+	case "":
+		return SortName, nil
+	case SortName, SortSize, SortMtime, SortLines, SortExt:
+		return cfg.Sort, nil
+	}
+	return "", fmt.Errorf("unknown sort order %q", cfg.Sort)
+}
+
+// sortEntries orders entries the way listings show them: each directory is
+// followed by its contents, and siblings are sorted by cfg.Sort, honoring
+// cfg.Reverse, cfg.DirsFirst and cfg.NaturalSort. Directories are sorted by
+// the totals of the files below them.
+func sortEntries(cfg *Config, entries []Entry) {
+	key, _ := sortKey(cfg)
+	var metas map[string]entryMeta
+	if key == SortSize || key == SortMtime || key == SortLines {
+		metas = entryMetas(cfg, entries, key == SortLines)
+	}
+
+	compare := func(a, b Entry) int {
+		ma, mb := metas[a.Path], metas[b.Path]
+		switch key {
+		case SortSize:
+			return compareInts(mb.size, ma.size)
+		case SortLines:
+			return compareInts(int64(mb.lines), int64(ma.lines))
+		case SortMtime:
+			return compareInts(mb.mtime.UnixNano(), ma.mtime.UnixNano())
+		case SortExt:
+			return strings.Compare(strings.ToLower(path.Ext(a.RelPath)), strings.ToLower(path.Ext(b.RelPath)))
+		}
+		return 0
+	}
+	less := func(a, b Entry) bool {
+		if cfg.DirsFirst && a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		c := compare(a, b)
+		if c == 0 {
+			c = compareNames(path.Base(a.RelPath), path.Base(b.RelPath), cfg.NaturalSort)
+		}
+		if cfg.Reverse {
+			return c > 0
+		}
+		return c < 0
+	}
+
+	children := make(map[string][]Entry)
+	for _, e := range entries {
+		dir := path.Dir(e.RelPath)
+		children[dir] = append(children[dir], e)
+	}
+	sorted := make([]Entry, 0, len(entries))
+	var visit func(dir string)
+	visit = func(dir string) {
+		kids := children[dir]
+		sort.SliceStable(kids, func(i, j int) bool { return less(kids[i], kids[j]) })
+		for _, e := range kids {
+			sorted = append(sorted, e)
+			if e.IsDir {
+				visit(e.RelPath)
+			}
+		}
+	}
+	visit(".")
+
+	// Entries whose parent isn't listed would be lost; keep the walk order
+	if len(sorted) == len(entries) {
+		copy(entries, sorted)
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNames compares file names, optionally in natural order, where
+// runs of digits compare by their value ("file2" before "file10")
+func compareNames(a, b string, natural bool) int {
+	if !natural {
+		return strings.Compare(a, b)
+	}
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, nb := digitRun(a), digitRun(b)
+			// Compare the values without leading zeros: longer is larger
+			va, vb := strings.TrimLeft(a[:na], "0"), strings.TrimLeft(b[:nb], "0")
+			if c := compareInts(int64(len(va)), int64(len(vb))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(va, vb); c != 0 {
+				return c
+			}
+			a, b = a[na:], b[nb:]
+			continue
+		}
+		if a[0] != b[0] {
+			return compareInts(int64(a[0]), int64(b[0]))
+		}
+		a, b = a[1:], b[1:]
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitRun returns the length of the run of digits s starts with
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+package mapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareNames(t *testing.T) {
+	cases := []struct {
+		a, b    string
+		natural bool
+		want    int
+	}{
+		{"file10", "file2", false, -1},
+		{"file10", "file2", true, 1},
+		{"file2", "file02", true, 0},
+		{"v1.10.0", "v1.9.3", true, 1},
+		{"a", "a1", true, -1},
+		{"b1", "a2", true, 1},
+	}
+	for _, c := range cases {
+		if got := compareNames(c.a, c.b, c.natural); got != c.want {
+			t.Errorf("compareNames(%q, %q, %v) = %d; want %d", c.a, c.b, c.natural, got, c.want)
+		}
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	tmp := t.TempDir()
+	files := map[string]string{
+		"file10.txt":    "1234567890",
+		"file2.md":      "12",
+		"lib/big.go":    strings.Repeat("x\n", 50),
+		"lib/small.txt": "x",
+		"zz/tiny.go":    "",
+	}
+	for rel, content := range files {
+		path := filepath.Join(tmp, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	walk := func(cfg *Config) string {
+		cfg.RootPath = tmp
+		entries, err := Walk(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rels []string
+		for _, e := range entries {
+			rels = append(rels, e.RelPath)
+		}
+		return strings.Join(rels, " ")
+	}
+
+	cases := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, "file10.txt file2.md lib lib/big.go lib/small.txt zz zz/tiny.go"},
+		{Config{NaturalSort: true}, "file2.md file10.txt lib lib/big.go lib/small.txt zz zz/tiny.go"},
+		{Config{DirsFirst: true}, "lib lib/big.go lib/small.txt zz zz/tiny.go file10.txt file2.md"},
+		{Config{Reverse: true}, "zz zz/tiny.go lib lib/small.txt lib/big.go file2.md file10.txt"},
+		{Config{Sort: SortSize}, "lib lib/big.go lib/small.txt file10.txt file2.md zz zz/tiny.go"},
+		{Config{Sort: SortLines, Reverse: true}, "zz zz/tiny.go file2.md file10.txt lib lib/small.txt lib/big.go"},
+		{Config{Sort: SortExt}, "lib lib/big.go lib/small.txt zz zz/tiny.go file2.md file10.txt"},
+	}
+	for i, c := range cases {
+		cfg := c.cfg
+		if got := walk(&cfg); got != c.want {
+			t.Errorf("case %d: Walk =\n  %s\nwant\n  %s", i, got, c.want)
+		}
+	}
+
+	// The tree and the separate content section follow the same order
+	out, err := Run(&Config{RootPath: tmp, ShowTree: true, ShowContent: true, SeparateContent: true, Sort: SortSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "├── lib\n│   ├── big.go\n│   └── small.txt\n├── file10.txt\n") {
+		t.Errorf("unexpected tree order:\n%s", out)
+	}
+	if i, j := strings.Index(out, "big.go (51 lines)"), strings.Index(out, "file10.txt (1 lines)"); i < 0 || j < 0 || i > j {
+		t.Errorf("content section not in sort order:\n%s", out)
+	}
+
+	if _, err := Walk(context.Background(), &Config{RootPath: tmp, Sort: "owner"}); err == nil {
+		t.Error("expected an error for an unknown sort order")
+	}
+}
@@ -0,0 +1,460 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sky93/file-mapper/internal/tokenizer"
+)
+
+// SplitUnit is what a SplitSize measures
+type SplitUnit int
+
+// Units for SplitSize
+const (
+	SplitBytes SplitUnit = iota
+	SplitLines
+	SplitTokens // counted with Config.Tokenizer
+)
+
+// SplitSize is the most a part written by RunSplit may hold
+type SplitSize struct {
+	N    int
+	Unit SplitUnit
+}
+
+// splitSuffixes maps the suffixes ParseSplitSize accepts to a unit and a
+// multiplier
+var splitSuffixes = map[string]struct {
+	unit  SplitUnit
+	scale int
+}{
+	"":       {SplitBytes, 1},
+	"b":      {SplitBytes, 1},
+	"k":      {SplitBytes, 1 << 10},
+	"kb":     {SplitBytes, 1 << 10},
+	"m":      {SplitBytes, 1 << 20},
+	"mb":     {SplitBytes, 1 << 20},
+	"l":      {SplitLines, 1},
+	"line":   {SplitLines, 1},
+	"lines":  {SplitLines, 1},
+	"t":      {SplitTokens, 1},
+	"token":  {SplitTokens, 1},
+	"tokens": {SplitTokens, 1},
+}
+
+// ParseSplitSize parses sizes such as "200KB", "1mb", "5000" (bytes),
+// "2000lines" or "8000tokens"
+func ParseSplitSize(s string) (SplitSize, error) {
+	t := strings.ToLower(strings.TrimSpace(s))
+	i := 0
+	for i < len(t) && t[i] >= '0' && t[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(t[:i])
+	suffix, ok := splitSuffixes[strings.TrimSpace(t[i:])]
+	if err != nil || !ok || n <= 0 {
+		return SplitSize{}, fmt.Errorf("invalid split size %q (want e.g. 200KB, 5000lines or 8000tokens)", s)
+	}
+	return SplitSize{N: n * suffix.scale, Unit: suffix.unit}, nil
+}
+
+// ChunkPath returns the file name of a part: "out.txt" becomes "out.001.txt"
+func ChunkPath(output string, part int) string {
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(output, ext), part, ext)
+}
+
+// RunSplit walks cfg.RootPath like RunTo but renders the output in parts no
+// larger than size, each written to create(part) and opened with a short
+// "part N of M" header. Every part is a complete document for its files.
+// A file is only split across parts when it doesn't fit in one on its own;
+// it's then cut at line boundaries with "continued" markers. RunSplit
+// returns how many parts it created, including one that failed midway.
+func RunSplit(ctx context.Context, cfg *Config, size SplitSize, create func(part int) (io.WriteCloser, error)) (int, error) {
+	renderer, err := chooseRenderer(cfg)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := walkEntries(ctx, cfg, true)
+	if err != nil {
+		return 0, err
+	}
+	// A revision's files are read through one git process
+	stop := entriesRun(entries).revTree().batch()
+	defer stop()
+
+	s := &splitter{ctx: ctx, cfg: cfg, renderer: renderer, entries: entries, size: size}
+	if size.Unit == SplitTokens {
+		if s.counter, err = tokenizer.New(cfg.Tokenizer); err != nil {
+			return 0, err
+		}
+	}
+	// Keep room for the widest header
+	s.limit = size.N - s.measureString(s.header(999, 999))
+
+	parts, err := s.plan()
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range parts {
+		w, err := create(i + 1)
+		if err != nil {
+			return i, err
+		}
+		err = s.write(w, p, i+1, len(parts))
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return i + 1, err
+		}
+	}
+	return len(parts), nil
+}
+
+// splitPart holds the files of one part. A file too large for any part is
+// spread over several, each holding a range of its lines.
+type splitPart struct {
+	files  []Entry
+	ranges map[string]lineRange
+	first  bool // the first part also lists directories without files
+}
+
+// lineRange limits a file's content to lines [from, to), counted the way
+// eachLine reports them. before and after are the parts holding the
+// neighboring pieces, named in the "continued" markers (0 for none).
+type lineRange struct {
+	from, to      int
+	before, after int
+}
+
+// splitter plans and writes the parts of RunSplit
+type splitter struct {
+	ctx      context.Context
+	cfg      *Config
+	renderer Renderer
+	entries  []Entry
+	size     SplitSize
+	counter  tokenizer.Counter // for SplitTokens
+	limit    int               // size.N less the header
+}
+
+// plan fills parts with files in walk order. Parts are sized by adding up
+// what each file costs rendered alone, measured once per file, and what
+// any part costs without files; only writing a part renders it in full.
+// Directories shared by the files of a part are counted for each of them,
+// so the sum errs on the safe side.
+func (s *splitter) plan() ([]*splitPart, error) {
+	base, err := s.measure(&splitPart{})
+	if err != nil {
+		return nil, err
+	}
+	// The first part also lists directories without files
+	firstBase, err := s.measure(&splitPart{first: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*splitPart
+	cur := &splitPart{first: true}
+	used := firstBase
+	for _, e := range s.entries {
+		if e.IsDir {
+			continue
+		}
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		alone, err := s.measure(&splitPart{files: []Entry{e}})
+		if err != nil {
+			return nil, err
+		}
+		cost := alone - base
+		if len(cur.files) > 0 && used+cost > s.limit {
+			parts = append(parts, cur)
+			cur, used = &splitPart{}, base
+		}
+
+		if alone > s.limit || used+cost > s.limit {
+			pieces, last, err := s.splitFile(e, len(parts)+1, cur.first, alone, firstBase-base)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, pieces[:len(pieces)-1]...)
+			cur, used = pieces[len(pieces)-1], last
+			continue
+		}
+
+		cur.files = append(cur.files, e)
+		used += cost
+	}
+	return append(parts, cur), nil
+}
+
+// splitFile cuts a file that doesn't fit in a part into line ranges, the
+// first going into part number firstPart. alone is what the whole file
+// costs in a part of its own, and extra what the first part costs on top
+// of any other. Lines are added while they fit; a single line longer than
+// the limit still gets a part of its own. splitFile also returns what the
+// last piece costs, as later files may join it.
+func (s *splitter) splitFile(e Entry, firstPart int, first bool, alone, extra int) ([]*splitPart, int, error) {
+	sizes, err := s.lineSizes(e)
+	if err != nil {
+		return nil, 0, err
+	}
+	piece := func(lr lineRange) *splitPart {
+		return &splitPart{files: []Entry{e}, ranges: map[string]lineRange{e.Path: lr}}
+	}
+
+	// What a part holding none of the lines costs, with and without the
+	// "continued" markers. What renderers add to each line (numbers,
+	// indentation, escaping) is spread evenly over the lines.
+	empty, err := s.measure(piece(lineRange{}))
+	if err != nil {
+		return nil, 0, err
+	}
+	overhead, err := s.measure(piece(lineRange{before: 999, after: 999}))
+	if err != nil {
+		return nil, 0, err
+	}
+	raw := 0
+	for _, n := range sizes {
+		raw += n
+	}
+	perLine := 0
+	if added := alone - empty - raw; added > 0 {
+		perLine = (added + len(sizes) - 1) / len(sizes)
+	}
+
+	var pieces []*splitPart
+	used := 0
+	for from := 0; from < len(sizes); {
+		used = overhead
+		if first && len(pieces) == 0 {
+			used += extra
+		}
+		to := from
+		for to < len(sizes) && (to == from || used+sizes[to]+perLine <= s.limit) {
+			used += sizes[to] + perLine
+			to++
+		}
+		// Don't leave the empty line after a final newline on its own
+		if to == len(sizes)-1 && sizes[to] == 0 {
+			to++
+		}
+
+		lr := lineRange{from: from, to: to}
+		if from > 0 {
+			lr.before = firstPart + len(pieces) - 1
+		}
+		if to < len(sizes) {
+			lr.after = firstPart + len(pieces) + 1
+		}
+		p := piece(lr)
+		p.first = first && len(pieces) == 0
+		pieces = append(pieces, p)
+		from = to
+	}
+	return pieces, used, nil
+}
+
+// lineSizes returns the size of each line of a file in the split unit. In
+// the built-in JSON format lines are measured escaped, as they're written.
+func (s *splitter) lineSizes(e Entry) ([]int, error) {
+	r, err := openContent(s.cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var sizes []int
+	err = eachLine(r, func(line string) {
+		if !s.jsonHeader() {
+			sizes = append(sizes, s.measureString(line))
+			return
+		}
+		c := s.newCounter()
+		w := bufio.NewWriter(c)
+		for i := 0; i < len(line); {
+			r, size := utf8.DecodeRuneInString(line[i:])
+			writeJSONRune(w, r, size)
+			i += size
+		}
+		w.Flush()
+		sizes = append(sizes, c.total())
+	})
+	return sizes, err
+}
+
+// measure returns the size of a part, without its header
+func (s *splitter) measure(p *splitPart) (int, error) {
+	c := s.newCounter()
+	if err := s.render(c, p); err != nil {
+		return 0, err
+	}
+	return c.total(), nil
+}
+
+// measureString returns the size of text in the split unit
+func (s *splitter) measureString(text string) int {
+	c := s.newCounter()
+	io.WriteString(c, text)
+	return c.total()
+}
+
+// render renders a part with the renderer chosen for the whole run
+func (s *splitter) render(w io.Writer, p *splitPart) error {
+	entries := keepEntries(s.entries, partFiles(p), p.first)
+	if p.ranges != nil {
+		// A file spread over parts shows only this part's lines
+		run := *entriesRun(entries)
+		run.ranges = p.ranges
+		for i := range entries {
+			entries[i].run = &run
+		}
+	}
+	return s.renderer.Render(s.ctx, w, s.cfg, entries)
+}
+
+// write writes a part with its header
+func (s *splitter) write(w io.Writer, p *splitPart, part, parts int) error {
+	if _, err := io.WriteString(w, s.header(part, parts)); err != nil {
+		return err
+	}
+	if s.jsonHeader() {
+		// The header opened the object already
+		w = &skipWriter{w: w, n: len("{\n")}
+	}
+	return s.render(w, p)
+}
+
+// header returns the "part N of M" line in the output's own syntax
+func (s *splitter) header(part, parts int) string {
+	switch {
+	case s.jsonHeader():
+		return fmt.Sprintf("{\n  \"part\": %d,\n  \"parts\": %d,\n", part, parts)
+	case s.cfg.Renderer == nil && (s.cfg.Format == FormatMarkdown || s.cfg.Format == FormatXML):
+		return fmt.Sprintf("<!-- part %d of %d -->\n", part, parts)
+	default:
+		return fmt.Sprintf("----- PART %d OF %d -----\n", part, parts)
+	}
+}
+
+// jsonHeader reports whether the header goes inside the built-in JSON
+// document rather than before it
+func (s *splitter) jsonHeader() bool {
+	return s.cfg.Renderer == nil && s.cfg.Format == FormatJSON
+}
+
+// partFiles returns the set of file paths in a part
+func partFiles(p *splitPart) map[string]bool {
+	files := make(map[string]bool, len(p.files))
+	for _, e := range p.files {
+		files[e.Path] = true
+	}
+	return files
+}
+
+// readLineRange returns the lines of r in lr, reading no further than the
+// line after them. The "continued" markers are left to the renderers, so
+// they stay out of line numbers and counts.
+func readLineRange(r io.Reader, lr lineRange) ([]byte, error) {
+	var buf bytes.Buffer
+	br := bufio.NewReader(r)
+	for n := 0; ; n++ {
+		// Lines are counted like eachLine does
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n >= lr.to {
+			// Only the file's last line ends without a newline; cutting
+			// the one of an earlier line keeps readers from seeing an
+			// empty line after it
+			return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+		}
+		if n >= lr.from {
+			buf.WriteString(line)
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+	}
+}
+
+// continuedMarker returns the line naming part n as the one a file is
+// continued "from" or "in", or "" if n is 0
+func continuedMarker(dir string, n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("----- continued %s part %d -----\n", dir, n)
+}
+
+// sizeCounter measures what's written to it in a SplitUnit
+type sizeCounter struct {
+	unit   SplitUnit
+	n      int
+	stream *tokenizer.Stream
+}
+
+func (s *splitter) newCounter() *sizeCounter {
+	c := &sizeCounter{unit: s.size.Unit}
+	if c.unit == SplitTokens {
+		c.stream = tokenizer.NewStream(s.counter)
+	}
+	return c
+}
+
+func (c *sizeCounter) Write(p []byte) (int, error) {
+	switch c.unit {
+	case SplitLines:
+		c.n += bytes.Count(p, []byte("\n"))
+	case SplitTokens:
+		c.stream.Write(p)
+	default:
+		c.n += len(p)
+	}
+	return len(p), nil
+}
+
+func (c *sizeCounter) total() int {
+	if c.unit == SplitTokens {
+		return c.stream.Total()
+	}
+	return c.n
+}
+
+// skipWriter drops the first n bytes written through it
+type skipWriter struct {
+	w io.Writer
+	n int
+}
+
+func (s *skipWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	if s.n > 0 {
+		k := s.n
+		if k > len(p) {
+			k = len(p)
+		}
+		s.n -= k
+		p = p[k:]
+	}
+	if len(p) == 0 {
+		return written, nil
+	}
+	if _, err := s.w.Write(p); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
@@ -0,0 +1,225 @@
+package mapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseSplitSize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want SplitSize
+		ok   bool
+	}{
+		{"5000", SplitSize{5000, SplitBytes}, true},
+		{"200KB", SplitSize{200 << 10, SplitBytes}, true},
+		{"1m", SplitSize{1 << 20, SplitBytes}, true},
+		{"2000lines", SplitSize{2000, SplitLines}, true},
+		{"8000 tokens", SplitSize{8000, SplitTokens}, true},
+		{"0", SplitSize{}, false},
+		{"tokens", SplitSize{}, false},
+		{"10 parsecs", SplitSize{}, false},
+	}
+	for _, c := range cases {
+		got, err := ParseSplitSize(c.in)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("ParseSplitSize(%q) = %v, %v; want %v (ok=%v)", c.in, got, err, c.want, c.ok)
+		}
+	}
+}
+
+func TestChunkPath(t *testing.T) {
+	cases := map[string]string{
+		"out.txt":        "out.002.txt",
+		"dir/dump.json":  "dir/dump.002.json",
+		"noext":          "noext.002",
+		"a.b/out.tar.gz": "a.b/out.tar.002.gz",
+	}
+	for in, want := range cases {
+		if got := ChunkPath(in, 2); got != want {
+			t.Errorf("ChunkPath(%q, 2) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+// nopCloser is an in-memory part for RunSplit
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+// runSplit runs RunSplit and returns the parts it wrote
+func runSplit(t *testing.T, cfg *Config, size SplitSize) []string {
+	t.Helper()
+	var bufs []*bytes.Buffer
+	n, err := RunSplit(context.Background(), cfg, size, func(part int) (io.WriteCloser, error) {
+		if part != len(bufs)+1 {
+			t.Fatalf("part %d requested after %d", part, len(bufs))
+		}
+		bufs = append(bufs, new(bytes.Buffer))
+		return nopCloser{bufs[len(bufs)-1]}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(bufs) {
+		t.Fatalf("RunSplit returned %d; created %d parts", n, len(bufs))
+	}
+	var parts []string
+	for _, b := range bufs {
+		parts = append(parts, b.String())
+	}
+	return parts
+}
+
+func TestRunSplit(t *testing.T) {
+	tmp := t.TempDir()
+	for i := 1; i <= 6; i++ {
+		content := strings.Repeat(fmt.Sprintf("file %d line\n", i), 20) // 240 bytes
+		os.WriteFile(filepath.Join(tmp, fmt.Sprintf("f%d.txt", i)), []byte(content), 0644)
+	}
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowContent: true, SeparateContent: true, ShowHeaderFooters: true}
+
+	parts := runSplit(t, cfg, SplitSize{1000, SplitBytes})
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+	seen := 0
+	for i, p := range parts {
+		if len(p) > 1000 {
+			t.Errorf("part %d is %d bytes", i+1, len(p))
+		}
+		if header := fmt.Sprintf("----- PART %d OF %d -----\n", i+1, len(parts)); !strings.HasPrefix(p, header) {
+			t.Errorf("part %d doesn't start with %q:\n%s", i+1, header, p)
+		}
+		if strings.Contains(p, "continued") {
+			t.Errorf("part %d splits a file that fits:\n%s", i+1, p)
+		}
+		seen += strings.Count(p, "----- CONTENT START -----")
+	}
+	if seen != 6 {
+		t.Errorf("parts hold %d files; want 6", seen)
+	}
+}
+
+func TestRunSplitLargeFile(t *testing.T) {
+	tmp := t.TempDir()
+	var sb strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	os.WriteFile(filepath.Join(tmp, "big.txt"), []byte(sb.String()), 0644)
+	os.WriteFile(filepath.Join(tmp, "small.txt"), []byte("tiny\n"), 0644)
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowContent: true, SeparateContent: true}
+
+	parts := runSplit(t, cfg, SplitSize{40, SplitLines})
+	var content strings.Builder
+	for i, p := range parts {
+		if n := strings.Count(p, "\n"); n > 40 {
+			t.Errorf("part %d has %d lines", i+1, n)
+		}
+		if i > 0 && !strings.Contains(p, fmt.Sprintf("----- continued from part %d -----\n", i)) && strings.Contains(p, "big.txt") {
+			t.Errorf("part %d lacks the continued-from marker:\n%s", i+1, p)
+		}
+		content.WriteString(p)
+	}
+	for _, want := range []string{"line 1\n", "line 57\n", "line 100\n", "tiny\n", "----- continued in part 2 -----\n"} {
+		if !strings.Contains(content.String(), want) {
+			t.Errorf("parts are missing %q", want)
+		}
+	}
+
+	// Line numbers go on from part to part and leave the markers out, and
+	// headers count the lines of the part
+	cfg.ShowLineNumbers = true
+	parts = runSplit(t, cfg, SplitSize{40, SplitLines})
+	header := regexp.MustCompile(`big\.txt \((\d+) lines\):\n`)
+	next := 1
+	for i, p := range parts {
+		m := header.FindStringSubmatch(p)
+		if m == nil {
+			continue
+		}
+		numbered := regexp.MustCompile(`(?m)^ *(\d+): line (\d+)$`).FindAllStringSubmatch(p, -1)
+		if len(numbered) == 0 || numbered[0][1] != strconv.Itoa(next) {
+			t.Errorf("part %d doesn't go on from line %d:\n%s", i+1, next, p)
+			continue
+		}
+		for _, n := range numbered {
+			if n[1] != n[2] {
+				t.Errorf("part %d numbers line %s as %s", i+1, n[2], n[1])
+			}
+		}
+		// The last part also has the empty line after the final newline
+		lines := len(numbered)
+		if strings.Contains(p, " 101: \n") {
+			lines++
+		}
+		if m[1] != strconv.Itoa(lines) {
+			t.Errorf("part %d header says %s lines; it shows %d:\n%s", i+1, m[1], lines, p)
+		}
+		if empty := regexp.MustCompile(`(?m)^ *\d+: $`).FindAllString(p, -1); len(empty) > 0 && (i < len(parts)-1 || len(empty) > 2) {
+			t.Errorf("part %d shows empty lines the file doesn't have:\n%s", i+1, p)
+		}
+		if regexp.MustCompile(`\d+: -+ continued`).MatchString(p) {
+			t.Errorf("part %d numbers a continued marker:\n%s", i+1, p)
+		}
+		next += len(numbered)
+	}
+	if next != 101 {
+		t.Errorf("parts number %d lines; want 100", next-1)
+	}
+}
+
+func TestRunSplitJSON(t *testing.T) {
+	tmp := t.TempDir()
+	for i := 1; i <= 4; i++ {
+		os.WriteFile(filepath.Join(tmp, fmt.Sprintf("f%d.txt", i)), []byte(strings.Repeat("some words here ", 50)), 0644)
+	}
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowContent: true, Format: FormatJSON}
+
+	parts := runSplit(t, cfg, SplitSize{600, SplitTokens})
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+	for i, p := range parts {
+		var doc struct {
+			Part  int       `json:"part"`
+			Parts int       `json:"parts"`
+			Tree  *jsonNode `json:"tree"`
+		}
+		if err := json.Unmarshal([]byte(p), &doc); err != nil {
+			t.Fatalf("part %d is not valid JSON: %v\n%s", i+1, err, p)
+		}
+		if doc.Part != i+1 || doc.Parts != len(parts) || doc.Tree == nil {
+			t.Errorf("part %d: got part %d of %d", i+1, doc.Part, doc.Parts)
+		}
+	}
+}
+
+func TestReadLineRange(t *testing.T) {
+	cases := []struct {
+		from, to int
+		want     string
+	}{
+		{0, 2, "a\nb"},
+		{1, 3, "b\nc"}, // the empty line after the last newline is line 3
+		{1, 4, "b\nc\n"},
+		{0, 0, ""},
+		{3, 4, ""},
+	}
+	for _, c := range cases {
+		got, err := readLineRange(strings.NewReader("a\nb\nc\n"), lineRange{from: c.from, to: c.to})
+		if err != nil || string(got) != c.want {
+			t.Errorf("readLineRange(%d, %d) = %q, %v; want %q", c.from, c.to, got, err, c.want)
+		}
+	}
+}
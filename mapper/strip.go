@@ -0,0 +1,276 @@
+package mapper
+
+import (
+	"bytes"
+)
+
+// commentSyntax describes how a language writes comments and the string
+// literals comment markers must not be looked for in
+type commentSyntax struct {
+	line      []string    // line comment markers
+	block     [][2]string // block comment delimiters
+	quotes    []quoteSyntax
+	wordStart bool     // line comments only start a line or follow whitespace (shell, YAML)
+	keep      []string // line comments that are kept, e.g. Go directives
+}
+
+// quoteSyntax is a kind of string literal. Longer openers must come first.
+type quoteSyntax struct {
+	open, close string
+	multiline   bool // may span lines
+	raw         bool // backslash doesn't escape
+}
+
+var (
+	doubleQuote = quoteSyntax{open: `"`, close: `"`}
+	singleQuote = quoteSyntax{open: `'`, close: `'`}
+	cBlock      = [][2]string{{"/*", "*/"}}
+)
+
+// cFamily is the syntax shared by C, Java, JavaScript and friends
+func cFamily(quotes ...quoteSyntax) *commentSyntax {
+	return &commentSyntax{line: []string{"//"}, block: cBlock, quotes: quotes}
+}
+
+// hashFamily is the syntax of Python, Ruby, shell and most config files
+func hashFamily(wordStart bool, quotes ...quoteSyntax) *commentSyntax {
+	return &commentSyntax{line: []string{"#"}, quotes: quotes, wordStart: wordStart}
+}
+
+var (
+	tripleDouble = quoteSyntax{open: `"""`, close: `"""`, multiline: true}
+	tripleSingle = quoteSyntax{open: `'''`, close: `'''`, multiline: true}
+	template     = quoteSyntax{open: "`", close: "`", multiline: true}
+)
+
+// commentSyntaxes maps the languages detectLanguage returns to their
+// comment syntax
+var commentSyntaxes = map[string]*commentSyntax{
+	"go": {
+		line:   []string{"//"},
+		block:  cBlock,
+		quotes: []quoteSyntax{doubleQuote, singleQuote, {open: "`", close: "`", multiline: true, raw: true}},
+		keep:   []string{"//go:", "// +build", "//export "},
+	},
+	"c":          cFamily(doubleQuote, singleQuote),
+	"cpp":        cFamily(doubleQuote, singleQuote),
+	"csharp":     cFamily(doubleQuote, singleQuote),
+	"java":       cFamily(tripleDouble, doubleQuote, singleQuote),
+	"javascript": cFamily(doubleQuote, singleQuote, template),
+	"jsx":        cFamily(doubleQuote, singleQuote, template),
+	"typescript": cFamily(doubleQuote, singleQuote, template),
+	"tsx":        cFamily(doubleQuote, singleQuote, template),
+	"kotlin":     cFamily(tripleDouble, doubleQuote, singleQuote),
+	"scala":      cFamily(tripleDouble, doubleQuote, singleQuote),
+	"swift":      cFamily(tripleDouble, doubleQuote),
+	"dart":       cFamily(tripleDouble, tripleSingle, doubleQuote, singleQuote),
+	"groovy":     cFamily(tripleDouble, tripleSingle, doubleQuote, singleQuote),
+	"protobuf":   cFamily(doubleQuote, singleQuote),
+	"rust":       cFamily(quoteSyntax{open: `"`, close: `"`, multiline: true}), // ' also marks lifetimes
+	"scss":       cFamily(doubleQuote, singleQuote),
+	"css":        {block: cBlock, quotes: []quoteSyntax{doubleQuote, singleQuote}},
+	"php":        {line: []string{"//", "#"}, block: cBlock, quotes: []quoteSyntax{doubleQuote, singleQuote}},
+	"hcl":        {line: []string{"#", "//"}, block: cBlock, quotes: []quoteSyntax{doubleQuote}},
+
+	"python":     hashFamily(false, tripleDouble, tripleSingle, doubleQuote, singleQuote),
+	"ruby":       hashFamily(false, doubleQuote, singleQuote),
+	"perl":       hashFamily(false, doubleQuote, singleQuote),
+	"r":          hashFamily(false, doubleQuote, singleQuote),
+	"bash":       hashFamily(true, doubleQuote, quoteSyntax{open: `'`, close: `'`, raw: true}),
+	"zsh":        hashFamily(true, doubleQuote, quoteSyntax{open: `'`, close: `'`, raw: true}),
+	"fish":       hashFamily(true, doubleQuote, singleQuote),
+	"powershell": {line: []string{"#"}, block: [][2]string{{"<#", "#>"}}, quotes: []quoteSyntax{doubleQuote, singleQuote}},
+	"dockerfile": hashFamily(true),
+	"makefile":   hashFamily(false),
+	"cmake":      hashFamily(false, doubleQuote),
+	"yaml":       hashFamily(true, doubleQuote, quoteSyntax{open: `'`, close: `'`, raw: true}),
+	"toml":       hashFamily(false, tripleDouble, tripleSingle, doubleQuote, quoteSyntax{open: `'`, close: `'`, raw: true}),
+	"ini":        {line: []string{";", "#"}, wordStart: true},
+
+	"sql": {
+		line:   []string{"--"},
+		block:  cBlock,
+		quotes: []quoteSyntax{{open: `'`, close: `'`, multiline: true, raw: true}, {open: `"`, close: `"`, raw: true}},
+	},
+	"html": {block: [][2]string{{"<!--", "-->"}}},
+	"xml":  {block: [][2]string{{"<!--", "-->"}}},
+	"vue":  {block: [][2]string{{"<!--", "-->"}}},
+}
+
+// stripComments removes the comments from src, leaving string literals
+// intact. Lines that only held a comment are dropped, and a shebang line
+// is kept.
+func stripComments(src []byte, syn *commentSyntax) []byte {
+	var out bytes.Buffer
+	out.Grow(len(src))
+	lineStart := 0    // where the current line starts in out
+	stripped := false // whether a comment was cut from the current line
+
+	// endLine finishes the current line, dropping it if removing a comment
+	// left it blank
+	endLine := func(newline bool) {
+		if stripped {
+			line := out.Bytes()[lineStart:]
+			cr := bytes.HasSuffix(line, []byte("\r"))
+			line = bytes.TrimRight(bytes.TrimSuffix(line, []byte("\r")), " \t")
+			if len(bytes.TrimLeft(line, " \t")) == 0 {
+				out.Truncate(lineStart)
+				newline = false
+			} else {
+				out.Truncate(lineStart + len(line))
+				if cr {
+					out.WriteByte('\r')
+				}
+			}
+		}
+		if newline {
+			out.WriteByte('\n')
+		}
+		lineStart = out.Len()
+		stripped = false
+	}
+
+	i := 0
+	if bytes.HasPrefix(src, []byte("#!")) {
+		i = lineEnd(src, 0)
+		out.Write(src[:i])
+	}
+	for i < len(src) {
+		if src[i] == '\n' {
+			endLine(true)
+			i++
+			continue
+		}
+
+		if q := syn.quoteAt(src, i); q != nil {
+			end := q.end(src, i)
+			out.Write(src[i:end])
+			if nl := bytes.LastIndexByte(src[i:end], '\n'); nl >= 0 {
+				lineStart = out.Len() - (end - i - nl - 1)
+				stripped = false
+			}
+			i = end
+			continue
+		}
+
+		if syn.lineCommentAt(src, i) {
+			end := lineEnd(src, i)
+			if syn.kept(src[i:]) {
+				out.Write(src[i:end])
+			} else {
+				stripped = true
+			}
+			i = end
+			continue
+		}
+
+		if closer, n := syn.blockCommentAt(src, i); n > 0 {
+			end := len(src)
+			if j := bytes.Index(src[i+n:], []byte(closer)); j >= 0 {
+				end = i + n + j + len(closer)
+			}
+			stripped = true
+			i = end
+			continue
+		}
+
+		out.WriteByte(src[i])
+		i++
+	}
+	endLine(false)
+	return out.Bytes()
+}
+
+// lineEnd returns the index of the line break (or of a "\r\n") at or after i
+func lineEnd(src []byte, i int) int {
+	j := i
+	for j < len(src) && src[j] != '\n' {
+		j++
+	}
+	if j > i && j < len(src) && src[j-1] == '\r' {
+		j--
+	}
+	return j
+}
+
+func (syn *commentSyntax) quoteAt(src []byte, i int) *quoteSyntax {
+	for k := range syn.quotes {
+		if bytes.HasPrefix(src[i:], []byte(syn.quotes[k].open)) {
+			return &syn.quotes[k]
+		}
+	}
+	return nil
+}
+
+// end returns the index just past the string literal starting at i; an
+// unterminated single-line literal ends with its line
+func (q *quoteSyntax) end(src []byte, i int) int {
+	j := i + len(q.open)
+	for j < len(src) {
+		switch {
+		case !q.raw && src[j] == '\\':
+			j += 2
+			continue
+		case src[j] == '\n' && !q.multiline:
+			return j
+		case bytes.HasPrefix(src[j:], []byte(q.close)):
+			return j + len(q.close)
+		}
+		j++
+	}
+	return len(src)
+}
+
+func (syn *commentSyntax) lineCommentAt(src []byte, i int) bool {
+	if syn.wordStart && i > 0 && src[i-1] != ' ' && src[i-1] != '\t' && src[i-1] != '\n' {
+		return false
+	}
+	for _, marker := range syn.line {
+		if bytes.HasPrefix(src[i:], []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockCommentAt returns the closing delimiter and the opener's length if
+// a block comment starts at i
+func (syn *commentSyntax) blockCommentAt(src []byte, i int) (string, int) {
+	for _, b := range syn.block {
+		if bytes.HasPrefix(src[i:], []byte(b[0])) {
+			return b[1], len(b[0])
+		}
+	}
+	return "", 0
+}
+
+func (syn *commentSyntax) kept(comment []byte) bool {
+	for _, prefix := range syn.keep {
+		if bytes.HasPrefix(comment, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapseBlankLines squeezes every run of blank lines into one
+func collapseBlankLines(src []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(src))
+	blank := false
+	for len(src) > 0 {
+		line := src
+		if i := bytes.IndexByte(src, '\n'); i >= 0 {
+			line = src[:i+1]
+		}
+		src = src[len(line):]
+
+		isBlank := len(bytes.TrimSpace(line)) == 0
+		if isBlank && blank {
+			continue
+		}
+		blank = isBlank
+		out.Write(line)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,66 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	cases := []struct {
+		lang string
+		src  string
+		want string
+	}{
+		{"go",
+			"// Package x\npackage x\n\n//go:embed a.txt\nvar a string // trailing\nvar s = \"// not a comment\" /* gone */\nvar r = `/* raw */`\n/*\n * block\n */\nfunc f() {}\n",
+			"package x\n\n//go:embed a.txt\nvar a string\nvar s = \"// not a comment\"\nvar r = `/* raw */`\nfunc f() {}\n"},
+		{"go", "var q = '\"' // quote\n", "var q = '\"'\n"},
+		{"javascript", "const u = `http://x ${a}`; // c\nlet s = 'it\\'s // fine'\n", "const u = `http://x ${a}`;\nlet s = 'it\\'s // fine'\n"},
+		{"python",
+			"#!/usr/bin/env python3\n# comment\ndef f():\n    \"\"\"Doc # kept\"\"\"\n    return '#' # note\n",
+			"#!/usr/bin/env python3\ndef f():\n    \"\"\"Doc # kept\"\"\"\n    return '#'\n"},
+		{"bash", "echo ${#arr} # count\n# whole\necho 'a # b'\n", "echo ${#arr}\necho 'a # b'\n"},
+		{"yaml", "url: http://x#frag # why\n# note\nname: 'a # b'\n", "url: http://x#frag\nname: 'a # b'\n"},
+		{"sql", "SELECT '--x' -- pick\nFROM t /* all */;\r\n", "SELECT '--x'\nFROM t ;\r\n"},
+		{"html", "<p>don't</p>\n<!-- hidden\n-->\n<b>x</b>\n", "<p>don't</p>\n<b>x</b>\n"},
+		{"c", "int x; // no newline at end", "int x;"},
+	}
+	for _, c := range cases {
+		if got := string(stripComments([]byte(c.src), commentSyntaxes[c.lang])); got != c.want {
+			t.Errorf("stripComments(%s, %q) = %q; want %q", c.lang, c.src, got, c.want)
+		}
+	}
+}
+
+func TestCollapseBlankLines(t *testing.T) {
+	got := string(collapseBlankLines([]byte("a\n\n\n  \nb\n\nc\n\n\n")))
+	if want := "a\n\nb\n\nc\n\n"; got != want {
+		t.Errorf("collapseBlankLines = %q; want %q", got, want)
+	}
+}
+
+func TestRunWithStripComments(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "main.go"), []byte("package main\n\n// hello\n\n\nfunc main() {} // bye\n"), 0644)
+	os.WriteFile(filepath.Join(tmp, "notes.txt"), []byte("# not code\n\n\nend\n"), 0644)
+
+	out, err := Run(&Config{
+		RootPath:           tmp,
+		ShowContent:        true,
+		SeparateContent:    true,
+		StripComments:      true,
+		CollapseBlankLines: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "package main\n\nfunc main() {}\n") || strings.Contains(out, "hello") {
+		t.Errorf("comments not stripped:\n%s", out)
+	}
+	// Unknown languages keep their comments but still lose extra blank lines
+	if !strings.Contains(out, "# not code\n\nend\n") {
+		t.Errorf("unexpected notes.txt content:\n%s", out)
+	}
+}
@@ -0,0 +1,99 @@
+package mapper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepoWithSubmodule creates a repository with a submodule at vendor/lib
+func newRepoWithSubmodule(t *testing.T) string {
+	t.Helper()
+	lib := newGitRepo(t, map[string]string{"lib.go": "package lib\n", "docs/readme.md": "# Lib\n"})
+	dir := newGitRepo(t, map[string]string{"main.go": "package main\n", "vendor/README": "vendored\n"})
+	runGit(t, dir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "vendor/lib")
+	runGit(t, dir, "commit", "-q", "-m", "add lib")
+	return dir
+}
+
+// walkPaths returns the relative paths Walk accepts, with their markers
+func walkPaths(t *testing.T, cfg *Config) string {
+	t.Helper()
+	entries, err := Walk(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		if e.NestedRepo != "" {
+			got = append(got, e.RelPath+"["+e.NestedRepo+"]")
+		} else {
+			got = append(got, e.RelPath)
+		}
+	}
+	return strings.Join(got, ",")
+}
+
+func TestRunSubmodules(t *testing.T) {
+	dir := newRepoWithSubmodule(t)
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		want string
+	}{
+		{"git", &Config{RootPath: dir, GitTrackedOnly: true},
+			"main.go,vendor,vendor/README,vendor/lib[submodule]"},
+		{"git recursive", &Config{RootPath: dir, GitTrackedOnly: true, RecurseSubmodules: true},
+			"main.go,vendor,vendor/README,vendor/lib[submodule],vendor/lib/docs,vendor/lib/docs/readme.md,vendor/lib/lib.go"},
+		{"git in a subdirectory", &Config{RootPath: dir + string(filepath.Separator) + "." + string(filepath.Separator) + "vendor", GitTrackedOnly: true, RecurseSubmodules: true},
+			"README,lib[submodule],lib/docs,lib/docs/readme.md,lib/lib.go"},
+		{"rev", &Config{RootPath: dir, Rev: "HEAD"},
+			"main.go,vendor,vendor/README,vendor/lib[submodule]"},
+		{"rev recursive", &Config{RootPath: dir, Rev: "HEAD", RecurseSubmodules: true},
+			"main.go,vendor,vendor/README,vendor/lib[submodule],vendor/lib/docs,vendor/lib/docs/readme.md,vendor/lib/lib.go"},
+	}
+	for _, tt := range tests {
+		if got := walkPaths(t, tt.cfg); got != tt.want {
+			t.Errorf("%s: Walk = %s; want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// The submodule's content comes from its own repository
+	out, err := Run(&Config{RootPath: dir, Rev: "HEAD", RecurseSubmodules: true, ShowTree: true, ShowContent: true, SeparateContent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"└── lib [submodule]\n", "package lib\n", "# Lib\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunLinkedWorktree(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{".gitignore": "*.log\n", "main.go": "package main\n", "pkg/a.go": "package pkg\n"})
+	if err := os.WriteFile(filepath.Join(dir, ".git", "info", "exclude"), []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt := filepath.Join(dir, "wt")
+	runGit(t, dir, "worktree", "add", "-q", "-b", "feature", wt)
+	writeFiles(t, wt, map[string]string{"debug.log": "x\n", "scratch.tmp": "x\n", "new.go": "package main\n"})
+
+	if got, want := walkPaths(t, &Config{RootPath: wt, UseGitignore: true}), "main.go,new.go,pkg,pkg/a.go"; got != want {
+		t.Errorf("worktree with .gitignore = %s; want %s", got, want)
+	}
+	if got, want := walkPaths(t, &Config{RootPath: wt, GitTrackedOnly: true}), "main.go,pkg,pkg/a.go"; got != want {
+		t.Errorf("worktree with Git-tracked files only = %s; want %s", got, want)
+	}
+	if got, want := walkPaths(t, &Config{RootPath: filepath.Join(wt, "pkg"), Rev: "HEAD"}), "a.go"; got != want {
+		t.Errorf("worktree subdirectory at HEAD = %s; want %s", got, want)
+	}
+
+	// Seen from the main working tree, the linked one is marked
+	if got := walkPaths(t, &Config{RootPath: dir, Exclude: "*.go,*.log,*.tmp"}); !strings.Contains(got, "wt[worktree]") {
+		t.Errorf("expected the linked worktree to be marked, got %s", got)
+	}
+}
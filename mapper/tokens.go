@@ -0,0 +1,155 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/sky93/file-mapper/internal/tokenizer"
+)
+
+// Tokenizers accepted by Config.Tokenizer (empty means TokenizerCL100K)
+const (
+	TokenizerCL100K = tokenizer.CL100K // offline BPE with the GPT-4 vocabulary
+	TokenizerChars  = tokenizer.Chars  // chars/4 estimate
+)
+
+// tokenReport holds the per-file token counts gathered for cfg.ShowTokens
+// and cfg.MaxTokens. A nil report means tokens aren't used at all; its
+// methods are safe to call.
+type tokenReport struct {
+	counter tokenizer.Counter
+	files   map[string]int // file path -> tokens in its content
+	show    bool           // cfg.ShowTokens; otherwise the counts only drive packing
+	omitted []omittedFile  // files dropped to fit cfg.MaxTokens
+}
+
+// newTokenReport counts the content of every file entry up front, since the
+// counts are printed in the tree before any content. It returns nil when
+// neither cfg.ShowTokens nor cfg.MaxTokens is set.
+func newTokenReport(ctx context.Context, cfg *Config, entries []Entry) (*tokenReport, error) {
+	if !cfg.ShowTokens && cfg.MaxTokens <= 0 {
+		return nil, nil
+	}
+	counter, err := tokenizer.New(cfg.Tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &tokenReport{counter: counter, files: make(map[string]int), show: cfg.ShowTokens}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if e.IsDir || e.Binary {
+			continue
+		}
+		r, err := openContent(cfg, e)
+		if err != nil {
+			continue
+		}
+		stream := tokenizer.NewStream(counter)
+		_, err = io.Copy(stream, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		stream.Write(fileDiff(cfg, e))
+		report.files[e.Path] = stream.Total()
+	}
+	return report, nil
+}
+
+// count returns the tokens of a file and whether they should be shown
+func (t *tokenReport) count(path string) (int, bool) {
+	if t == nil || !t.show {
+		return 0, false
+	}
+	n, ok := t.files[path]
+	return n, ok
+}
+
+// label returns the " (N tokens)" suffix for a file in a listing
+func (t *tokenReport) label(path string) string {
+	n, ok := t.count(path)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (%d tokens)", n)
+}
+
+// shown reports whether token counts and the total are printed
+func (t *tokenReport) shown() bool {
+	return t != nil && t.show
+}
+
+// keptFiles returns how many counted files made it into the output
+func (t *tokenReport) keptFiles() int {
+	n := len(t.files)
+	for _, o := range t.omitted {
+		if _, ok := t.files[o.Path]; ok {
+			n--
+		}
+	}
+	return n
+}
+
+// tally runs body against a writer that feeds w and also counts tokens, and
+// returns the tokens of everything body wrote. Without a report body just
+// writes to w.
+func (t *tokenReport) tally(w *bufio.Writer, body func(w *bufio.Writer) error) (int, error) {
+	if t == nil {
+		return 0, body(w)
+	}
+	stream := tokenizer.NewStream(t.counter)
+	counted := bufio.NewWriter(io.MultiWriter(w, stream))
+	if err := body(counted); err != nil {
+		return 0, err
+	}
+	if err := counted.Flush(); err != nil {
+		return 0, err
+	}
+	return stream.Total(), nil
+}
+
+// tokenBody writes a built-in format up to its token footer. It lists the
+// entries given, which may have been packed to fit cfg.MaxTokens, followed
+// by any omitted files.
+type tokenBody func(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error
+
+// tokenFooter writes the total (if tokens are shown) and closes the document
+type tokenFooter func(w *bufio.Writer, cfg *Config, tokens *tokenReport, total int)
+
+// writeWithTokens renders a built-in format: it counts tokens when asked to,
+// packs the entries into cfg.MaxTokens, then writes body and footer.
+func writeWithTokens(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, body tokenBody, footer tokenFooter) error {
+	tokens, err := newTokenReport(ctx, cfg, entries)
+	if err != nil {
+		return err
+	}
+	if cfg.MaxTokens > 0 {
+		entries, err = tokens.pack(ctx, cfg, entries, func(kept []Entry) (int, error) {
+			// Render into the void to see what the dump really costs
+			return tokens.tally(bufio.NewWriter(io.Discard), func(w *bufio.Writer) error {
+				if err := body(ctx, w, cfg, kept, tokens); err != nil {
+					return err
+				}
+				footer(w, cfg, tokens, cfg.MaxTokens)
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	total, err := tokens.tally(w, func(w *bufio.Writer) error {
+		return body(ctx, w, cfg, entries, tokens)
+	})
+	if err != nil {
+		return err
+	}
+	footer(w, cfg, tokens, total)
+	return nil
+}
@@ -0,0 +1,121 @@
+package mapper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tokenTree creates a small tree whose cl100k token counts are known
+func tokenTree(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, "sub"), 0755)
+	os.WriteFile(filepath.Join(tmp, "hello.txt"), []byte("hello world"), 0644)          // 2 tokens
+	os.WriteFile(filepath.Join(tmp, "sub", "greet.txt"), []byte("Hello, world!"), 0644) // 4 tokens
+	return tmp
+}
+
+func TestRunWithTokens(t *testing.T) {
+	tmp := tokenTree(t)
+	cfg := &Config{
+		RootPath:          tmp,
+		ShowTree:          true,
+		ShowContent:       true,
+		SeparateContent:   true,
+		ShowHeaderFooters: true,
+		ShowTokens:        true,
+	}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"├── hello.txt (2 tokens)\n",
+		"│   └── greet.txt (4 tokens)\n",
+		"hello.txt (1 lines, 2 tokens):\n",
+		"greet.txt (1 lines, 4 tokens):\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, " tokens (2 files)\n") || !strings.Contains(out, "\n\nTotal: ") {
+		t.Errorf("output should end with the total:\n%s", out)
+	}
+
+	// Without --tokens nothing changes
+	cfg.ShowTokens = false
+	out, err = Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "tokens") {
+		t.Errorf("token counts shown without ShowTokens:\n%s", out)
+	}
+}
+
+func TestRunWithTokensFormats(t *testing.T) {
+	tmp := tokenTree(t)
+
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowContent: true, ShowTokens: true, Format: FormatXML}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `<document index="1" tokens="2">`) || !strings.Contains(out, "</total_tokens>\n</documents>\n") {
+		t.Errorf("unexpected XML:\n%s", out)
+	}
+
+	cfg.Format = FormatMarkdown
+	if out, err = Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "hello.txt (2 tokens)") || !strings.Contains(out, "\n**Total:** ") {
+		t.Errorf("unexpected Markdown:\n%s", out)
+	}
+
+	cfg.Format = FormatJSON
+	if out, err = Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Tree struct {
+			Children []struct {
+				Name   string `json:"name"`
+				Tokens *int   `json:"tokens"`
+			} `json:"children"`
+		} `json:"tree"`
+		TotalTokens int `json:"total_tokens"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if c := doc.Tree.Children[0]; c.Name != "hello.txt" || c.Tokens == nil || *c.Tokens != 2 {
+		t.Errorf("hello.txt node = %+v", c)
+	}
+	if doc.TotalTokens <= 6 {
+		t.Errorf("total_tokens = %d; should cover the whole document", doc.TotalTokens)
+	}
+}
+
+func TestRunWithCharsTokenizer(t *testing.T) {
+	tmp := tokenTree(t)
+	cfg := &Config{RootPath: tmp, ShowTree: true, ShowTokens: true, Tokenizer: TokenizerChars}
+	out, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "hello world" is 11 characters, about 3 tokens
+	if !strings.Contains(out, "hello.txt (3 tokens)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	cfg.Tokenizer = "nope"
+	if _, err := Run(cfg); err == nil {
+		t.Error("expected an error for an unknown tokenizer")
+	}
+}
@@ -0,0 +1,187 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeXML renders a <documents> root holding the tree (or flat list)
+// in a <directory_tree> element and, if cfg.ShowContent is set, one
+// <document index="N"> per file, the layout recommended for multi-file
+// LLM prompts. With cfg.ShowTokens the listing carries token counts, each
+// document a tokens attribute, and a <total_tokens> element closes the root.
+func writeXML(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry) error {
+	return writeWithTokens(ctx, w, cfg, entries, writeXMLBody, writeXMLFooter)
+}
+
+// writeXMLFooter writes the token total and closes the root element
+func writeXMLFooter(w *bufio.Writer, cfg *Config, tokens *tokenReport, total int) {
+	if tokens.shown() {
+		fmt.Fprintf(w, "<total_tokens files=\"%d\">%d</total_tokens>\n", tokens.keptFiles(), total)
+	}
+	w.WriteString("</documents>\n")
+}
+
+// writeXMLBody writes everything of the XML format but the token total and
+// the closing </documents> tag, ending with the files omitted to fit
+// cfg.MaxTokens
+func writeXMLBody(ctx context.Context, w *bufio.Writer, cfg *Config, entries []Entry, tokens *tokenReport) error {
+	listing, files := buildPlainListing(cfg, entries, tokens)
+	w.WriteString("<documents>\n")
+	w.WriteString("<directory_tree>\n")
+	if err := writeXMLText(w, cfg, strings.NewReader(listing), strings.ContainsAny(listing, "<>&"), 0); err != nil {
+		return err
+	}
+	w.WriteString("</directory_tree>\n")
+
+	if cfg.ShowContent {
+		if err := writeXMLDocuments(ctx, w, cfg, files, tokens); err != nil {
+			return err
+		}
+	}
+
+	if tokens != nil && len(tokens.omitted) > 0 {
+		fmt.Fprintf(w, "<omitted_files budget=\"%d\">\n", cfg.MaxTokens)
+		for _, o := range tokens.omitted {
+			fmt.Fprintf(w, "<file tokens=\"%d\" reason=\"%s\">%s</file>\n", o.Tokens, escapeXML(o.Reason), escapeXML(o.Path))
+		}
+		w.WriteString("</omitted_files>\n")
+	}
+	return nil
+}
+
+// writeXMLDocuments writes one <document> per file
+func writeXMLDocuments(ctx context.Context, w *bufio.Writer, cfg *Config, files []Entry, tokens *tokenReport) error {
+	index := 0
+	for _, e := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := e.Path
+		info, err := statFile(e.run, path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// Whether CDATA is needed has to be known up front
+		r, stats, err := openContentStats(cfg, e)
+		if err != nil {
+			continue
+		}
+
+		index++
+		if n, ok := tokens.count(path); ok {
+			fmt.Fprintf(w, "<document index=\"%d\" tokens=\"%d\">\n", index, n)
+		} else {
+			fmt.Fprintf(w, "<document index=\"%d\">\n", index)
+		}
+		w.WriteString("<source>" + escapeXML(path) + "</source>\n")
+		if c := e.LastCommit; c != nil {
+			fmt.Fprintf(w, "<last_commit hash=\"%s\" author=\"%s\" date=\"%s\">%s</last_commit>\n",
+				escapeXML(c.Hash), escapeXML(c.Author), c.Date.Format(time.RFC3339), escapeXML(c.Subject))
+		}
+		// A file spread over RunSplit parts names the parts with the rest
+		lr, _ := e.run.lines(path)
+		if lr.before > 0 {
+			fmt.Fprintf(w, "<continued_from part=\"%d\"/>\n", lr.before)
+		}
+		first := 0
+		if cfg.ShowLineNumbers {
+			first = lr.from + 1
+		}
+		w.WriteString("<document_content>\n")
+		err = writeXMLText(w, cfg, r, stats.HasMarkup, first)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		w.WriteString("</document_content>\n")
+		if lr.after > 0 {
+			fmt.Fprintf(w, "<continued_in part=\"%d\"/>\n", lr.after)
+		}
+		if diff := fileDiff(cfg, e); diff != nil {
+			w.WriteString("<document_diff>\n")
+			if err := writeXMLText(w, cfg, bytes.NewReader(diff), bytes.ContainsAny(diff, "<>&"), 0); err != nil {
+				return err
+			}
+			w.WriteString("</document_diff>\n")
+		}
+		w.WriteString("</document>\n")
+	}
+	return nil
+}
+
+// writeXMLText streams r as element content, followed by a newline.
+// Text without markup characters is written verbatim so prompts stay
+// readable; anything else goes into CDATA sections. Either way, control
+// characters XML doesn't allow become U+FFFD, as in escapeXML. Lines are
+// numbered from first, unless it's 0.
+func writeXMLText(w *bufio.Writer, cfg *Config, r io.Reader, hasMarkup bool, first int) error {
+	r = &xmlCharReader{r: r}
+	if !hasMarkup {
+		if first > 0 {
+			return writeLines(w, cfg, r, "", first)
+		}
+		return copyContent(w, r)
+	}
+
+	// "]]>" can't appear inside CDATA, so split it across two sections.
+	// It never spans a newline, so replacing line by line is safe.
+	w.WriteString("<![CDATA[")
+	n := first - 1
+	err := eachLine(r, func(line string) {
+		n++
+		if first > 0 {
+			fmt.Fprintf(w, "%4d: ", n)
+			line = strings.TrimSuffix(line, "\n") + "\n"
+		}
+		w.WriteString(strings.ReplaceAll(line, "]]>", "]]]]><![CDATA[>"))
+	})
+	if err != nil {
+		return err
+	}
+	w.WriteString("]]>\n")
+	return nil
+}
+
+// xmlCharReader replaces the control characters XML 1.0 doesn't allow
+// (all below U+0020 but tab, newline and carriage return) with U+FFFD. They
+// are single bytes in UTF-8, so the content can be filtered as it streams.
+type xmlCharReader struct {
+	r       io.Reader
+	pending []byte
+}
+
+func (x *xmlCharReader) Read(p []byte) (int, error) {
+	for len(x.pending) == 0 {
+		buf := make([]byte, len(p))
+		n, err := x.r.Read(buf)
+		for _, b := range buf[:n] {
+			if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+				x.pending = append(x.pending, "\uFFFD"...)
+			} else {
+				x.pending = append(x.pending, b)
+			}
+		}
+		if len(x.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, x.pending)
+	x.pending = x.pending[n:]
+	return n, nil
+}
+
+// escapeXML escapes s for use in an element or attribute
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,94 @@
+package mapper
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteXML(t *testing.T) {
+	tmp := t.TempDir()
+	plain := filepath.Join(tmp, "a.txt")
+	os.WriteFile(plain, []byte("hello"), 0644)
+	markup := filepath.Join(tmp, "b&c.html")
+	os.WriteFile(markup, []byte("<p>x]]>y</p>\n"), 0644)
+
+	cfg := &Config{
+		RootPath:    tmp,
+		Format:      FormatXML,
+		ShowTree:    true,
+		ShowContent: true,
+	}
+	out := render(t, func(w *bufio.Writer) error {
+		return writeXML(context.Background(), w, cfg, entriesFor(t, tmp, plain, markup))
+	})
+
+	// The output must be well-formed and round-trip the original content
+	var doc struct {
+		Tree      string `xml:"directory_tree"`
+		Documents []struct {
+			Index   int    `xml:"index,attr"`
+			Source  string `xml:"source"`
+			Content string `xml:"document_content"`
+		} `xml:"document"`
+	}
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("Invalid XML: %v\n%s", err, out)
+	}
+	if !strings.Contains(doc.Tree, "a.txt") || !strings.Contains(doc.Tree, "b&c.html") {
+		t.Errorf("Expected both files in the directory tree, got %q", doc.Tree)
+	}
+	if len(doc.Documents) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(doc.Documents))
+	}
+	if doc.Documents[0].Index != 1 || doc.Documents[0].Source != plain || doc.Documents[0].Content != "\nhello\n" {
+		t.Errorf("Unexpected first document: %+v", doc.Documents[0])
+	}
+	if doc.Documents[1].Index != 2 || doc.Documents[1].Source != markup || doc.Documents[1].Content != "\n<p>x]]>y</p>\n\n" {
+		t.Errorf("Unexpected second document: %+v", doc.Documents[1])
+	}
+
+	// Without content only the tree is emitted
+	cfg.ShowContent = false
+	out = render(t, func(w *bufio.Writer) error {
+		return writeXML(context.Background(), w, cfg, entriesFor(t, tmp, plain))
+	})
+	if strings.Contains(out, "<document ") {
+		t.Errorf("Expected no documents without ShowContent, got:\n%s", out)
+	}
+}
+
+func TestWriteXMLControlChars(t *testing.T) {
+	tmp := t.TempDir()
+	plain := filepath.Join(tmp, "ansi.log")
+	os.WriteFile(plain, []byte("\x1b[31mred\x1b[0m\fnext\n"), 0644)
+	markup := filepath.Join(tmp, "bell.html")
+	os.WriteFile(markup, []byte("<b>\x07</b>\n"), 0644)
+
+	cfg := &Config{RootPath: tmp, Format: FormatXML, ShowContent: true}
+	for _, numbered := range []bool{false, true} {
+		cfg.ShowLineNumbers = numbered
+		out := render(t, func(w *bufio.Writer) error {
+			return writeXML(context.Background(), w, cfg, entriesFor(t, tmp, plain, markup))
+		})
+
+		// Characters XML doesn't allow would make the document malformed
+		var doc struct {
+			Documents []struct {
+				Content string `xml:"document_content"`
+			} `xml:"document"`
+		}
+		if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+			t.Fatalf("Invalid XML: %v\n%q", err, out)
+		}
+		if len(doc.Documents) != 2 ||
+			!strings.Contains(doc.Documents[0].Content, "\uFFFD[31mred\uFFFD[0m\uFFFDnext") ||
+			!strings.Contains(doc.Documents[1].Content, "<b>\uFFFD</b>") {
+			t.Errorf("Unexpected documents: %+v", doc.Documents)
+		}
+	}
+}